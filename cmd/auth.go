@@ -4,34 +4,84 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/own"
 )
 
 var authCmd = &cobra.Command{
 	Use:   "auth",
-	Short: "Log in or out",
+	Short: "Log in or out and manage stored accounts",
 }
 
 var authLoginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Log in",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return auth.Login(User)
+		return auth.Login(cmd.Context(), User, authInstance, oob)
 	},
 }
 
 var authLogoutCmd = &cobra.Command{
 	Use:   "logout",
-	Short: "Log out",
+	Short: "Log out, revoking the access token and removing stored credentials",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return auth.Logout(User)
+		return auth.Logout(cmd.Context(), User, forgetApp)
 	},
 }
 
 var authWhoamiCmd = &cobra.Command{
 	Use:   "whoami",
-	Short: "Display the default currently authenticated user, if there is one",
+	Short: "Verify the current user's access token and display their account and role",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return auth.Whoami()
+		authClient, err := auth.NewAuthClient(User)
+		if err != nil {
+			return err
+		}
+		return own.Whoami(cmd.Context(), authClient)
+	},
+}
+
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored users, their instances, and whether we have a token for them",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return auth.List()
+	},
+}
+
+var authSwitchCmd = &cobra.Command{
+	Use:   "switch <user>",
+	Short: "Make a stored user the default",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return auth.Switch(args[0])
+	},
+}
+
+var authStoreCmd = &cobra.Command{
+	Use:       "store [auto|keyring|file|env]",
+	Short:     "Show the credential store in use, or set the default one",
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: auth.StoreNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return auth.ShowCredentialStore()
+		}
+		return auth.SetDefaultCredentialStore(args[0])
+	},
+}
+
+var authTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage access tokens directly",
+}
+
+var authTokenSetCmd = &cobra.Command{
+	Use:   "set <user>",
+	Short: "Store an existing access token, read from stdin, for a user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return auth.ImportToken(cmd.Context(), args[0], authInstance, cmd.InOrStdin(), own.Verify)
 	},
 }
 
@@ -41,4 +91,14 @@ func init() {
 	authCmd.AddCommand(authLoginCmd)
 	authCmd.AddCommand(authLogoutCmd)
 	authCmd.AddCommand(authWhoamiCmd)
+	authCmd.AddCommand(authListCmd)
+	authCmd.AddCommand(authSwitchCmd)
+	authCmd.AddCommand(authStoreCmd)
+	authCmd.AddCommand(authTokenCmd)
+	authTokenCmd.AddCommand(authTokenSetCmd)
+
+	authLoginCmd.Flags().BoolVar(&oob, "oob", false, "Paste the authorization code manually instead of receiving it on a local callback URL")
+	authLoginCmd.Flags().StringVar(&authInstance, "instance", "", "Instance host or base URL (e.g. http://localhost:8080) for the user, skipping WebFinger lookup")
+	authTokenSetCmd.Flags().StringVar(&authInstance, "instance", "", "Instance host or base URL (e.g. http://localhost:8080) for the user, skipping WebFinger lookup")
+	authLogoutCmd.Flags().BoolVar(&forgetApp, "forget-app", false, "Also remove the instance's OAuth2 app credentials")
 }
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/CDN18/femoji-cli/internal/backend"
+	"github.com/CDN18/femoji-cli/internal/list"
+)
+
+var categoriesCmd = &cobra.Command{
+	Use:   "categories [instance]",
+	Short: "List an instance's emoji categories and how many emojis are in each",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instance, authClient, err := listedInstance(args)
+		if err != nil {
+			return err
+		}
+
+		return list.Categories(cmd.Context(), authClient, instance, list.CategoryOptions{
+			Software: instanceType,
+			Format:   format,
+			Sort:     categorySort,
+			Reverse:  reverse,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(categoriesCmd)
+	categoriesCmd.Flags().StringVar(&instanceType, "software", backend.Auto, "Instance software family ("+strings.Join(backend.FamilyNames(), ", ")+"); auto detects it from NodeInfo")
+	categoriesCmd.Flags().StringVar(&format, "format", "table", "Output format ("+strings.Join(list.Formats, ", ")+")")
+	categoriesCmd.Flags().StringVar(&categorySort, "sort", "name", "Sort by "+strings.Join(list.CategorySortKeys, ", "))
+	categoriesCmd.Flags().BoolVar(&reverse, "reverse", false, "Reverse the sort order")
+}
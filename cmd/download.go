@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/backend"
 	"github.com/CDN18/femoji-cli/internal/download"
 )
 
 var downloadCmd = &cobra.Command{
-	Use:   "download [instance] [category] --software mastodon|misskey",
+	Use: "download [instance] [category] --software auto|mastodon|pleroma|misskey",
+	Example: `  femoji download example.social --shortcode 'blobcat*' --exclude nsfw
+  femoji download example.social --category 'blob*' --uncategorized --static-only
+  femoji download example.social --shortcode '/^(blob|neo)cat_/'`,
 	Short: "Download emojis from an instance",
 	Args:  cobra.MaximumNArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -21,19 +27,36 @@ var downloadCmd = &cobra.Command{
 		if len(args) > 0 {
 			instance = args[0]
 		}
-		category := "*"
-		if len(args) > 1 {
-			category = args[1]
+		var extraCategories []string
+		if len(args) > 1 && args[1] != "*" {
+			extraCategories = append(extraCategories, args[1])
+		}
+		f, err := buildFilter(extraCategories...)
+		if err != nil {
+			return err
 		}
 
-		return download.Download(authClient, instance, category, override, instanceType, multithread, saveIndex)
+		return download.Download(cmd.Context(), authClient, instance, download.Options{
+			Filter:    f,
+			Override:  override,
+			Software:  instanceType,
+			Threads:   multithread,
+			SaveIndex: saveIndex,
+			Packs:     packs,
+			Output:    output,
+			Layout:    layout,
+		})
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(downloadCmd)
 	downloadCmd.Flags().BoolVar(&override, "override", false, "Override existing files when downloading")
-	downloadCmd.Flags().StringVar(&instanceType, "software", "mastodon", "Instance type (mastodon or misskey)")
+	downloadCmd.Flags().StringVar(&instanceType, "software", backend.Auto, "Instance software family ("+strings.Join(backend.FamilyNames(), ", ")+"); auto detects it from NodeInfo")
 	downloadCmd.Flags().IntVar(&multithread, "multithread", 0, "Enable multi-threaded download with specified number of threads (default: number of CPU cores)")
 	downloadCmd.Flags().BoolVar(&saveIndex, "save-index", false, "Save server response as index.json")
+	downloadCmd.Flags().BoolVar(&packs, "packs", true, "Download Pleroma/Akkoma emoji packs as directories with their pack.json")
+	addFilterFlags(downloadCmd)
+	downloadCmd.Flags().StringVarP(&output, "output", "o", ".", "Directory to save downloads under")
+	downloadCmd.Flags().StringVar(&layout, "layout", "default", "Where to save each emoji under --output: default ("+download.DefaultLayout+"), flat ("+download.LayoutPresets["flat"]+"), or a template using {instance}, {category}, {shortcode} and {ext}; any but the default turns off --packs")
 }
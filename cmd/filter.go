@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/CDN18/femoji-cli/internal/filter"
+)
+
+// addFilterFlags registers the flags that choose which emojis a command works with.
+func addFilterFlags(cmd *cobra.Command) {
+	addNameFilterFlags(cmd)
+	cmd.Flags().BoolVar(&animatedOnly, "animated-only", false, "Only animated emojis")
+	cmd.Flags().BoolVar(&staticOnly, "static-only", false, "Only static emojis")
+	cmd.MarkFlagsMutuallyExclusive("animated-only", "static-only")
+}
+
+// addNameFilterFlags registers the filter flags that go by shortcode and category,
+// for commands that don't look at the images themselves.
+func addNameFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&categories, "category", nil, "Only emojis in categories matching this glob, or /regex/ (repeatable)")
+	cmd.Flags().StringArrayVar(&shortcodes, "shortcode", nil, "Only emojis with shortcodes matching this glob, or /regex/ (repeatable)")
+	cmd.Flags().StringArrayVar(&excludes, "exclude", nil, "Leave out emojis whose shortcode or category matches this glob, or /regex/ (repeatable)")
+	cmd.Flags().BoolVar(&uncategorized, "uncategorized", false, "Only emojis without a category, plus any given with --category")
+}
+
+// buildFilter compiles the filter flags, adding any extra categories given as arguments.
+func buildFilter(extraCategories ...string) (*filter.Filter, error) {
+	spec := filter.Spec{
+		Categories:    append(categories, extraCategories...),
+		Uncategorized: uncategorized,
+		Shortcodes:    shortcodes,
+		Exclude:       excludes,
+	}
+	switch {
+	case animatedOnly:
+		spec.Animation = filter.AnimatedOnly
+	case staticOnly:
+		spec.Animation = filter.StaticOnly
+	}
+	return filter.New(spec)
+}
@@ -5,4 +5,35 @@ var (
 	instanceType string
 	multithread  int
 	saveIndex    bool
+	packs        bool
+	forgetApp    bool
+	oob          bool
+	authInstance string
+	insecureHTTP bool
+	output       string
+	layout       string
+	format       string
+	sortBy       string
+	categorySort string
+	onConflict   string
+	renamePrefix string
+	renameSuffix string
+	recursive    bool
+	depth        int
+	categoryMap  map[string]string
+	indexFile    string
+	maxDimension int
+	validateOnly bool
+	convert      bool
+	resize       int
+	recompress   bool
+	gifPreviews  bool
+	reverse      bool
+
+	categories    []string
+	shortcodes    []string
+	excludes      []string
+	uncategorized bool
+	animatedOnly  bool
+	staticOnly    bool
 )
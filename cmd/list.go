@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/backend"
+	"github.com/CDN18/femoji-cli/internal/list"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list [instance]",
+	Short: "List the emojis on an instance",
+	Example: `  femoji list
+  femoji list example.social --format csv --sort category
+  femoji list example.social --shortcode 'blobcat*' --format json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instance, authClient, err := listedInstance(args)
+		if err != nil {
+			return err
+		}
+
+		f, err := buildFilter()
+		if err != nil {
+			return err
+		}
+
+		return list.List(cmd.Context(), authClient, instance, list.Options{
+			Software: instanceType,
+			Filter:   f,
+			Format:   format,
+			Sort:     sortBy,
+			Reverse:  reverse,
+		})
+	},
+}
+
+// listedInstance returns the instance named in args, or "DEFAULT" and a client for the logged-in user if there isn't one.
+// Other instances are listed through their public API, so the user only has to be logged in for their own.
+func listedInstance(args []string) (string, *auth.Client, error) {
+	if len(args) > 0 {
+		return args[0], nil, nil
+	}
+	authClient, err := auth.NewAuthClient(User)
+	if err != nil {
+		return "", nil, err
+	}
+	return "DEFAULT", authClient, nil
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVar(&instanceType, "software", backend.Auto, "Instance software family ("+strings.Join(backend.FamilyNames(), ", ")+"); auto detects it from NodeInfo")
+	listCmd.Flags().StringVar(&format, "format", "table", "Output format ("+strings.Join(list.Formats, ", ")+")")
+	listCmd.Flags().StringVar(&sortBy, "sort", "shortcode", "Sort by "+strings.Join(list.SortKeys, ", "))
+	listCmd.Flags().BoolVar(&reverse, "reverse", false, "Reverse the sort order")
+	addNameFilterFlags(listCmd)
+}
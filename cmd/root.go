@@ -1,22 +1,43 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
+
+	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/util"
 )
 
 var rootCmd = &cobra.Command{
 	Use:     "femoji",
 	Short:   "Femoji is a tool for managing custom emojis on Fediverse instances",
 	Version: Version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Arguments have been accepted by now, so later errors aren't about usage.
+		cmd.SilenceUsage = true
+		util.SetAllowInsecureHTTP(insecureHTTP)
+		return auth.SetCredentialStore(CredentialStore)
+	},
 }
 
 var User string
 var File string
+var CredentialStore string
 
 func Execute() {
-	err := rootCmd.Execute()
+	// Cancel on the first Ctrl-C so work in progress can stop cleanly; a second one exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
 		os.Exit(1)
 	}
@@ -24,4 +45,6 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&User, "user", "u", "", "username@domain of the account whose data we're working with")
+	rootCmd.PersistentFlags().StringVar(&CredentialStore, "credential-store", "", "Where to keep credentials: auto, keyring, file, or env (default from prefs, else auto)")
+	rootCmd.PersistentFlags().BoolVar(&insecureHTTP, "insecure-http", false, "Allow instances served over plain HTTP (for local development only)")
 }
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"github.com/CDN18/femoji-cli/internal/auth"
@@ -10,22 +12,70 @@ import (
 var uploadCmd = &cobra.Command{
 	Use:   "upload <path> [category]",
 	Short: "Upload emojis from a directory",
-	Args:  cobra.RangeArgs(1, 2),
+	Example: `  femoji upload ./blobcats blobcat
+  femoji upload ./mastodon.social --recursive
+  femoji upload ./packs --recursive --depth 2 --category-map blobs=Blobs
+  femoji upload ./mastodon.social --index ./mastodon.social/index.json
+  femoji upload ./photos --convert --resize 128 --recompress`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		authClient, err := auth.NewAuthClient(User)
 		if err != nil {
 			return err
 		}
 		path := args[0]
-		category := "uncategorized"
+		var category string
 		if len(args) == 2 {
 			category = args[1]
 		}
-		return upload.Upload(authClient, path, category, override)
+		f, err := buildFilter()
+		if err != nil {
+			return err
+		}
+		// --override predates --on-conflict, and means the same as replace.
+		strategy := upload.OnConflict(onConflict)
+		if override && !cmd.Flags().Changed("on-conflict") {
+			strategy = upload.Replace
+		}
+
+		return upload.Upload(cmd.Context(), authClient, path, upload.Options{
+			Category:     category,
+			Recursive:    recursive,
+			Depth:        depth,
+			CategoryMap:  categoryMap,
+			Index:        indexFile,
+			MaxDimension: maxDimension,
+			ValidateOnly: validateOnly,
+			Process: &upload.ProcessOptions{
+				Convert:     convert,
+				Resize:      resize,
+				Recompress:  recompress,
+				GIFPreviews: gifPreviews,
+			},
+			Filter:       f,
+			OnConflict:   strategy,
+			RenamePrefix: renamePrefix,
+			RenameSuffix: renameSuffix,
+		})
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(uploadCmd)
 	uploadCmd.Flags().BoolVar(&override, "override", false, "Override existing emojis with the same shortcode")
+	_ = uploadCmd.Flags().MarkDeprecated("override", "use --on-conflict replace instead")
+	uploadCmd.Flags().StringVar(&onConflict, "on-conflict", string(upload.Skip), "What to do when a shortcode is already taken ("+strings.Join(upload.ConflictStrategies, ", ")+")")
+	uploadCmd.Flags().StringVar(&renamePrefix, "rename-prefix", "", "With --on-conflict rename, add this before taken shortcodes")
+	uploadCmd.Flags().StringVar(&renameSuffix, "rename-suffix", "", "With --on-conflict rename, add this after taken shortcodes (numbered if still taken)")
+	uploadCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Upload subdirectories too, each as a category named after it; [category] is used for images at the top level")
+	uploadCmd.Flags().IntVar(&depth, "depth", 1, "With --recursive, how many levels of subdirectories to go into (0 for no limit)")
+	uploadCmd.Flags().StringToStringVar(&categoryMap, "category-map", nil, "With --recursive, use a different category for a directory, as directory=category (repeatable)")
+	uploadCmd.Flags().StringVar(&indexFile, "index", "", "Take shortcodes and categories from an index: index.json from download --save-index, a Misskey meta.json, or a Pleroma pack.json")
+	uploadCmd.Flags().IntVar(&maxDimension, "max-dimension", 0, "Reject images wider or taller than this many pixels (0 for no limit)")
+	uploadCmd.Flags().BoolVar(&validateOnly, "validate-only", false, "Check images against the instance's emoji limits and print the report, without uploading")
+	uploadCmd.Flags().BoolVar(&convert, "convert", false, "Convert static images in formats the instance doesn't accept (JPEG, BMP, TIFF) to PNG")
+	uploadCmd.Flags().IntVar(&resize, "resize", 0, "Downscale static images so neither side is longer than this many pixels")
+	uploadCmd.Flags().BoolVar(&recompress, "recompress", false, "Losslessly recompress PNGs that are over the instance's emoji size limit")
+	uploadCmd.Flags().BoolVar(&gifPreviews, "gif-previews", false, "Also upload the first frame of each animated GIF as a static emoji named <shortcode>_static")
+	addFilterFlags(uploadCmd)
 }
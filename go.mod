@@ -8,6 +8,9 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/cobra v1.8.1
 	github.com/zalando/go-keyring v0.2.6
+	golang.org/x/crypto v0.26.0
+	golang.org/x/image v0.18.0
+	golang.org/x/term v0.25.0
 	golang.org/x/time v0.7.0
 	webfinger.net/go/webfinger v0.1.0
 )
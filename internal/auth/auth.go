@@ -4,19 +4,20 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	neturl "net/url"
 	"os"
+	"slices"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/go-openapi/runtime"
 	httptransport "github.com/go-openapi/runtime/client"
 	"github.com/go-openapi/strfmt"
 	"github.com/pkg/browser"
 	"github.com/pkg/errors"
-	"github.com/zalando/go-keyring"
-	"golang.org/x/time/rate"
 	"webfinger.net/go/webfinger"
 
 	"github.com/CDN18/femoji-cli/internal/util"
@@ -25,26 +26,29 @@ import (
 	"github.com/owu-one/gotosocial-sdk/models"
 )
 
-// Client is a GtS API client with attached authentication credentials and rate limiter.
-// Credentials may be no-op.
+// Client is a GtS API client with attached authentication credentials.
+// Credentials may be no-op. Requests are rate limited by util.HTTPClient.
 type Client struct {
-	Client  *apiclient.GoToSocialSwaggerDocumentation
-	Auth    runtime.ClientAuthInfoWriter
-	limiter *rate.Limiter
-	ctx     context.Context
-}
-
-func (c *Client) Wait() error {
-	if err := c.limiter.Wait(c.ctx); err != nil {
-		return errors.WithStack(err)
-	}
-
-	return nil
+	Client *apiclient.GoToSocialSwaggerDocumentation
+	Auth   runtime.ClientAuthInfoWriter
+	// User is the username@domain the credentials belong to. It may be empty if they came from the environment.
+	User string
+	// Instance is the host of the instance API.
+	Instance string
 }
 
 func NewAuthClient(user string) (*Client, error) {
 	var err error
 
+	creds, err := credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	if usesEnvCredentials() {
+		return newEnvAuthClient(creds)
+	}
+
 	if user == "" {
 		user, err = util.GetDefaultUser()
 		if err != nil {
@@ -59,17 +63,47 @@ func NewAuthClient(user string) (*Client, error) {
 		return nil, err
 	}
 
-	accessToken, err := keyring.Get(keyringServiceAccessToken, user)
+	accessToken, err := creds.Get(keyringServiceAccessToken, user)
 	if err != nil {
 		slog.Error("couldn't find user's access token (did you log in first?)", "user", user)
 		return nil, err
 	}
 
+	return newClient(user, instance, accessToken)
+}
+
+// newEnvAuthClient creates a client for the instance and token given in the environment,
+// without consulting prefs.
+func newEnvAuthClient(creds CredentialStore) (*Client, error) {
+	instance := os.Getenv(envInstance)
+	if instance == "" {
+		return nil, errors.Errorf("%s must be set when using the env credential store", envInstance)
+	}
+	instance, err := util.NormalizeInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := creds.Get(keyringServiceAccessToken, "")
+	if err != nil {
+		slog.Error("couldn't find access token in environment", "env", envToken)
+		return nil, err
+	}
+
+	return newClient("", instance, accessToken)
+}
+
+func newClient(user string, instance string, accessToken string) (*Client, error) {
+	client, err := clientForInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Client{
-		Client:  clientForInstance(instance),
-		Auth:    httptransport.BearerToken(accessToken),
-		limiter: rate.NewLimiter(1.0, 300),
-		ctx:     context.Background(),
+		Client:   client,
+		Auth:     httptransport.BearerToken(accessToken),
+		User:     user,
+		Instance: instance,
 	}, nil
 }
 
@@ -83,8 +117,11 @@ const (
 	oauthScopes   = "read write admin"
 )
 
-// Login authenticates the user and saves the credentials in the system keychain.
-func Login(user string) error {
+// Login authenticates the user and saves the credentials in the selected credential store.
+// By default the authorization code is captured by a temporary loopback listener;
+// if oob is set, or the browser can't be opened, the user pastes the code instead.
+// If instance is empty, it's found by WebFinger lookup.
+func Login(ctx context.Context, user string, instance string, oob bool) error {
 	var err error
 
 	if user == "" {
@@ -95,44 +132,73 @@ func Login(user string) error {
 		}
 	}
 
-	if user == "" {
-		return errors.WithStack(errors.New("a user is required"))
-	}
-	if !strings.ContainsRune(user, '@') {
-		return errors.WithStack(errors.New("a fully qualified user with a domain is required"))
+	err = validateUser(user)
+	if err != nil {
+		return err
 	}
-	if user[0] == '@' {
-		return errors.WithStack(errors.New("take the leading @ off the user and try again"))
+
+	creds, err := credentials()
+	if err != nil {
+		return err
 	}
 
-	if _, err := keyring.Get(keyringServiceAccessToken, user); err == nil {
+	if _, err := creds.Get(keyringServiceAccessToken, user); err == nil {
 		slog.Warn("already logged in, will log in again", "user", user)
 	}
 
-	instance, err := ensureInstance(user)
+	instance, err = ensureInstance(user, instance)
 	if err != nil {
 		slog.Error("couldn't get user's instance", "user", user, "error", err)
 		return err
 	}
 
-	client := clientForInstance(instance)
-	clientID, clientSecret, err := ensureAppCredentials(instance, client)
+	state, err := randomToken()
+	if err != nil {
+		return err
+	}
+	codeVerifier, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	redirectURI := oauthRedirect
+	var loopback *loopbackServer
+	if !oob {
+		redirectURIs, _ := util.GetInstanceRedirectURIs(instance)
+		loopback, err = startLoopbackServer(loopbackPort(redirectURIs), state)
+		if err != nil {
+			slog.Warn("couldn't start loopback listener, falling back to pasting the authorization code", "error", err)
+		} else {
+			defer loopback.Close()
+			redirectURI = loopback.redirectURI
+		}
+	}
+
+	client, err := clientForInstance(instance)
+	if err != nil {
+		return err
+	}
+	clientID, clientSecret, err := ensureAppCredentials(ctx, creds, instance, client, redirectURI)
 	if err != nil {
 		slog.Error("OAuth2 app setup failed", "user", user, "instance", instance, "error", err)
 		return err
 	}
 
-	code := promptForOAuthCode(instance, clientID)
+	code, redirectURI, err := authorize(ctx, instance, clientID, state, codeVerifier, loopback)
+	if err != nil {
+		slog.Error("OAuth2 authorization failed", "user", user, "instance", instance, "error", err)
+		return err
+	}
 
-	accessToken, err := exchangeCodeForToken(instance, clientID, clientSecret, code)
+	accessToken, err := exchangeCodeForToken(ctx, instance, clientID, clientSecret, code, redirectURI, codeVerifier)
 	if err != nil {
 		slog.Error("couldn't exchange OAuth2 authorization code for access token", "user", user, "instance", instance, "error", err)
 		return err
 	}
 
-	err = keyring.Set(keyringServiceAccessToken, user, accessToken)
+	err = creds.Set(keyringServiceAccessToken, user, accessToken)
 	if err != nil {
-		slog.Error("couldn't set access token in keychain", "user", user, "instance", instance, "error", err)
+		slog.Error("couldn't store access token", "user", user, "instance", instance, "error", err)
 		return err
 	}
 
@@ -147,16 +213,39 @@ func Login(user string) error {
 	return nil
 }
 
-// ensureInstance finds a user's instance or retrieves a previously cached instance for them.
-func ensureInstance(user string) (string, error) {
-	if instance, err := util.GetUserInstance(user); err == nil {
-		return instance, nil
+// validateUser checks that a user is given as username@domain.
+func validateUser(user string) error {
+	if user == "" {
+		return errors.WithStack(errors.New("a user is required"))
+	}
+	if !strings.ContainsRune(user, '@') {
+		return errors.WithStack(errors.New("a fully qualified user with a domain is required"))
+	}
+	if user[0] == '@' {
+		return errors.WithStack(errors.New("take the leading @ off the user and try again"))
 	}
 
-	instance, err := findInstance(user)
-	if err != nil {
-		slog.Error("WebFinger lookup failed", "user", user, "error", err)
-		return "", err
+	return nil
+}
+
+// ensureInstance finds a user's instance or retrieves a previously cached instance for them.
+// If an instance is given, it's used and cached instead.
+func ensureInstance(user string, instance string) (string, error) {
+	var err error
+
+	if instance != "" {
+		instance, err = util.NormalizeInstance(instance)
+		if err != nil {
+			return "", err
+		}
+	} else if instance, err = util.GetUserInstance(user); err == nil {
+		return instance, nil
+	} else {
+		instance, err = findInstance(user)
+		if err != nil {
+			slog.Error("WebFinger lookup failed", "user", user, "error", err)
+			return "", err
+		}
 	}
 
 	err = util.SetUserInstance(user, instance)
@@ -168,9 +257,9 @@ func ensureInstance(user string) (string, error) {
 	return instance, nil
 }
 
-// findInstance does a WebFinger lookup to find the domain of the instance API for a given user.
+// findInstance does a WebFinger lookup to find the instance API for a given user.
 func findInstance(user string) (string, error) {
-	webfingerClient := webfinger.NewClient(nil)
+	webfingerClient := webfinger.NewClient(util.HTTPClient)
 	jrd, err := webfingerClient.Lookup(user, nil)
 	if err != nil {
 		return "", err
@@ -192,34 +281,57 @@ func findInstance(user string) (string, error) {
 		return "", err
 	}
 
-	if url.Scheme != "https" || !(url.Port() == "" || url.Port() == "443") || url.Hostname() == "" {
+	if url.Hostname() == "" {
 		return "", errors.New("unexpected URL format")
 	}
 
-	return url.Hostname(), nil
+	return util.NormalizeInstance(url.Scheme + "://" + url.Host)
 }
 
-func clientForInstance(instance string) *apiclient.GoToSocialSwaggerDocumentation {
-	return apiclient.New(httptransport.New(instance, "", []string{"https"}), strfmt.Default)
+func clientForInstance(instance string) (*apiclient.GoToSocialSwaggerDocumentation, error) {
+	url, err := util.ParseInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiclient.New(httptransport.NewWithClient(url.Host, "", []string{url.Scheme}, util.HTTPClient), strfmt.Default), nil
 }
 
 // ensureAppCredentials retrieves or creates and stores app credentials.
-func ensureAppCredentials(instance string, client *apiclient.GoToSocialSwaggerDocumentation) (string, string, error) {
+// A new app is created if the existing one wasn't registered with the given redirect URI.
+// The OOB redirect URI is always registered too, so that we can fall back to it.
+func ensureAppCredentials(ctx context.Context, creds CredentialStore, instance string, client *apiclient.GoToSocialSwaggerDocumentation, redirectURI string) (string, string, error) {
 	shouldCreateNewApp := false
 
+	redirectURIs := []string{redirectURI}
+	if redirectURI != oauthRedirect {
+		redirectURIs = append(redirectURIs, oauthRedirect)
+	}
+
+	registeredRedirectURIs, err := util.GetInstanceRedirectURIs(instance)
+	if err == nil && len(registeredRedirectURIs) == 0 {
+		// Apps created before we started recording redirect URIs only used OOB.
+		registeredRedirectURIs = []string{oauthRedirect}
+	}
+	for _, uri := range redirectURIs {
+		if !slices.Contains(registeredRedirectURIs, uri) {
+			shouldCreateNewApp = true
+		}
+	}
+
 	clientID, err := util.GetInstanceClientID(instance)
-	if clientID == "" || errors.Is(err, keyring.ErrNotFound) {
+	if clientID == "" || errors.Is(err, util.PrefNotFound) {
 		shouldCreateNewApp = true
 	} else if err != nil {
 		slog.Error("couldn't get client ID from prefs", "instance", instance, "error", err)
 		return "", "", err
 	}
 
-	clientSecret, err := keyring.Get(keyringServiceClientSecret, instance)
-	if clientSecret == "" || errors.Is(err, keyring.ErrNotFound) {
+	clientSecret, err := creds.Get(keyringServiceClientSecret, instance)
+	if clientSecret == "" || errors.Is(err, ErrCredentialNotFound) {
 		shouldCreateNewApp = true
 	} else if err != nil {
-		slog.Error("couldn't get client secret from keychain", "instance", instance, "error", err)
+		slog.Error("couldn't get client secret", "instance", instance, "error", err)
 		return "", "", err
 	}
 
@@ -227,7 +339,7 @@ func ensureAppCredentials(instance string, client *apiclient.GoToSocialSwaggerDo
 		return clientID, clientSecret, nil
 	}
 
-	app, err := createApp(client)
+	app, err := createApp(ctx, client, redirectURIs)
 	if err != nil {
 		slog.Error("couldn't create OAuth2 app", "instance", instance, "error", err)
 		return "", "", err
@@ -240,9 +352,14 @@ func ensureAppCredentials(instance string, client *apiclient.GoToSocialSwaggerDo
 		slog.Error("couldn't set client ID in prefs", "instance", instance, "error", err)
 		return "", "", err
 	}
-	err = keyring.Set(keyringServiceClientSecret, instance, clientSecret)
+	err = util.SetInstanceRedirectURIs(instance, redirectURIs)
 	if err != nil {
-		slog.Error("couldn't set client secret in keychain", "instance", instance, "error", err)
+		slog.Error("couldn't set redirect URIs in prefs", "instance", instance, "error", err)
+		return "", "", err
+	}
+	err = creds.Set(keyringServiceClientSecret, instance, clientSecret)
+	if err != nil {
+		slog.Error("couldn't store client secret", "instance", instance, "error", err)
 		return "", "", err
 	}
 
@@ -250,13 +367,14 @@ func ensureAppCredentials(instance string, client *apiclient.GoToSocialSwaggerDo
 }
 
 // createApp registers a new OAuth2 application.
-func createApp(client *apiclient.GoToSocialSwaggerDocumentation) (*models.Application, error) {
+func createApp(ctx context.Context, client *apiclient.GoToSocialSwaggerDocumentation, redirectURIs []string) (*models.Application, error) {
 	resp, err := client.Apps.AppCreate(
 		&apps.AppCreateParams{
 			ClientName:   "femoji",
-			RedirectURIs: oauthRedirect,
+			RedirectURIs: strings.Join(redirectURIs, "\n"),
 			Scopes:       util.Ptr(oauthScopes),
 			Website:      util.Ptr("https://github.com/CDN18/femoji"),
+			Context:      ctx,
 		},
 		func(op *runtime.ClientOperation) {
 			op.ConsumesMediaTypes = []string{"application/x-www-form-urlencoded"}
@@ -268,22 +386,56 @@ func createApp(client *apiclient.GoToSocialSwaggerDocumentation) (*models.Applic
 	return resp.GetPayload(), nil
 }
 
-func promptForOAuthCode(instance string, clientID string) string {
-	oauthAuthorizeURL := (&neturl.URL{
-		Scheme: "https",
-		Host:   instance,
-		Path:   "/oauth/authorize",
-		RawQuery: neturl.Values{
-			"response_type": []string{"code"},
-			"client_id":     []string{clientID},
-			"redirect_uri":  []string{oauthRedirect},
-			"scope":         []string{oauthScopes},
-		}.Encode(),
-	}).String()
-	err := browser.OpenURL(oauthAuthorizeURL)
+// authorize sends the user to the instance's authorization page and returns the resulting code
+// along with the redirect URI it was issued for.
+func authorize(ctx context.Context, instance string, clientID string, state string, codeVerifier string, loopback *loopbackServer) (string, string, error) {
+	if loopback != nil {
+		oauthAuthorizeURL, err := authorizeURL(instance, clientID, loopback.redirectURI, state, codeVerifier)
+		if err != nil {
+			return "", "", err
+		}
+		err = browser.OpenURL(oauthAuthorizeURL)
+		if err == nil {
+			slog.Info("waiting for authorization in browser", "redirect_uri", loopback.redirectURI)
+			code, err := loopback.wait(ctx)
+			return code, loopback.redirectURI, err
+		}
+		slog.Warn("couldn't open browser, falling back to pasting the authorization code", "error", err)
+	}
+
+	code, err := promptForOAuthCode(instance, clientID, state, codeVerifier)
+	return code, oauthRedirect, err
+}
+
+func authorizeURL(instance string, clientID string, redirectURI string, state string, codeVerifier string) (string, error) {
+	url, err := util.ParseInstance(instance)
+	if err != nil {
+		return "", err
+	}
+
+	url.Path = "/oauth/authorize"
+	url.RawQuery = neturl.Values{
+		"response_type":         []string{"code"},
+		"client_id":             []string{clientID},
+		"redirect_uri":          []string{redirectURI},
+		"scope":                 []string{oauthScopes},
+		"state":                 []string{state},
+		"code_challenge":        []string{pkceChallenge(codeVerifier)},
+		"code_challenge_method": []string{"S256"},
+	}.Encode()
+
+	return url.String(), nil
+}
+
+func promptForOAuthCode(instance string, clientID string, state string, codeVerifier string) (string, error) {
+	oauthAuthorizeURL, err := authorizeURL(instance, clientID, oauthRedirect, state, codeVerifier)
+	if err != nil {
+		return "", err
+	}
+	err = browser.OpenURL(oauthAuthorizeURL)
 	if err != nil {
 		slog.Warn("couldn't open browser to authorize", "error", err)
-		print("Please open this URL in your browser:", oauthAuthorizeURL)
+		println("Please open this URL in your browser:", oauthAuthorizeURL)
 	}
 
 	print("Enter authorization code: ")
@@ -291,7 +443,7 @@ func promptForOAuthCode(instance string, clientID string) string {
 	scanner.Scan()
 	code := strings.TrimSpace(scanner.Text())
 
-	return code
+	return code, nil
 }
 
 type oauthTokenOK struct {
@@ -306,23 +458,24 @@ type oauthTokenError struct {
 	ErrorDescription string `json:"error_description"`
 }
 
-// exchangeCodeForToken exchanges an authorization code for an access token.
-func exchangeCodeForToken(instance string, clientID string, clientSecret string, code string) (string, error) {
-	oauthTokenURL := (&neturl.URL{
-		Scheme: "https",
-		Host:   instance,
-		Path:   "/oauth/token",
-	}).String()
+// exchangeCodeForToken exchanges an authorization code for an access token,
+// proving possession of the PKCE code verifier used to request it.
+func exchangeCodeForToken(ctx context.Context, instance string, clientID string, clientSecret string, code string, redirectURI string, codeVerifier string) (string, error) {
+	oauthTokenURL, err := util.InstanceURL(instance, "/oauth/token")
+	if err != nil {
+		return "", err
+	}
 
 	// TODO: add this to GtS Swagger doc
-	resp, err := http.Post(oauthTokenURL, "application/x-www-form-urlencoded", strings.NewReader(neturl.Values{
+	resp, err := postForm(ctx, oauthTokenURL, neturl.Values{
 		"grant_type":    []string{"authorization_code"},
 		"code":          []string{code},
 		"client_id":     []string{clientID},
 		"client_secret": []string{clientSecret},
-		"redirect_uri":  []string{oauthRedirect},
+		"redirect_uri":  []string{redirectURI},
 		"scope":         []string{oauthScopes},
-	}.Encode()))
+		"code_verifier": []string{codeVerifier},
+	})
 	if err != nil {
 		slog.Error("call to OAuth2 token endpoint failed", "instance", instance, "error", err)
 		return "", err
@@ -360,22 +513,246 @@ func exchangeCodeForToken(instance string, clientID string, clientSecret string,
 	return payload.AccessToken, nil
 }
 
-func Logout(user string) error {
-	// TODO: revoke token
-	// TODO: remove token from keychain
-	// TODO: remove client ID from keychain
-	// TODO: remove client secret from keychain
-	return errors.New("NOT IMPLEMENTED")
+// Logout revokes the user's access token and removes their credentials from the credential store and prefs.
+// If forgetApp is set, the instance's OAuth2 app credentials are removed as well,
+// unless other users are still logged in to the instance with them.
+func Logout(ctx context.Context, user string, forgetApp bool) error {
+	var err error
+
+	if user == "" {
+		user, err = util.GetDefaultUser()
+		if err != nil {
+			slog.Error("no user provided, couldn't get default user from prefs (are you logged in?)")
+			return err
+		}
+	}
+
+	instance, err := util.GetUserInstance(user)
+	if err != nil {
+		slog.Error("couldn't get user's instance from prefs (are you logged in?)", "user", user)
+		return err
+	}
+
+	creds, err := credentials()
+	if err != nil {
+		return err
+	}
+
+	accessToken, err := creds.Get(keyringServiceAccessToken, user)
+	if errors.Is(err, ErrCredentialNotFound) {
+		slog.Warn("no access token found, nothing to revoke", "user", user)
+	} else if err != nil {
+		slog.Error("couldn't get access token", "user", user, "error", err)
+		return err
+	} else {
+		err = revokeToken(ctx, creds, instance, accessToken)
+		if err != nil {
+			// The token may already be invalid, so keep going and clean up local state anyway.
+			slog.Warn("couldn't revoke access token, removing it locally anyway", "user", user, "instance", instance, "error", err)
+		}
+
+		err = creds.Delete(keyringServiceAccessToken, user)
+		if err != nil && !errors.Is(err, ErrCredentialNotFound) {
+			slog.Error("couldn't remove access token", "user", user, "error", err)
+			return err
+		}
+	}
+
+	err = util.RemoveUser(user)
+	if err != nil {
+		slog.Error("couldn't remove user from prefs", "user", user, "error", err)
+		return err
+	}
+
+	if forgetApp {
+		others, err := util.InstanceUsers(instance)
+		if err != nil {
+			slog.Error("couldn't check for other users of the instance", "instance", instance, "error", err)
+			return err
+		}
+		if len(others) > 0 {
+			slog.Warn("not removing OAuth2 app credentials, as other users are still logged in to the instance with them", "instance", instance, "users", strings.Join(others, ", "))
+			forgetApp = false
+		}
+	}
+
+	if forgetApp {
+		err = util.RemoveInstance(instance)
+		if err != nil {
+			slog.Error("couldn't remove client ID from prefs", "instance", instance, "error", err)
+			return err
+		}
+
+		err = creds.Delete(keyringServiceClientSecret, instance)
+		if err != nil && !errors.Is(err, ErrCredentialNotFound) {
+			slog.Error("couldn't remove client secret", "instance", instance, "error", err)
+			return err
+		}
+
+		slog.Info("removed OAuth2 app credentials", "instance", instance)
+	}
+
+	slog.Info("logout successful", "user", user, "instance", instance)
+
+	return nil
 }
 
-func Whoami() error {
-	user, err := util.GetDefaultUser()
+// revokeToken invalidates an access token on the instance.
+func revokeToken(ctx context.Context, creds CredentialStore, instance string, accessToken string) error {
+	clientID, err := util.GetInstanceClientID(instance)
+	if err != nil {
+		return err
+	}
+
+	clientSecret, err := creds.Get(keyringServiceClientSecret, instance)
+	if err != nil {
+		return err
+	}
+
+	oauthRevokeURL, err := util.InstanceURL(instance, "/oauth/revoke")
+	if err != nil {
+		return err
+	}
+
+	resp, err := postForm(ctx, oauthRevokeURL, neturl.Values{
+		"client_id":     []string{clientID},
+		"client_secret": []string{clientSecret},
+		"token":         []string{accessToken},
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var payload oauthTokenError
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || payload.ErrorDescription == "" {
+			return errors.Errorf("OAuth2 revoke endpoint returned status %d", resp.StatusCode)
+		}
+		return errors.WithStack(errors.New(payload.ErrorDescription))
+	}
+
+	return nil
+}
+
+// postForm sends a form-encoded POST request.
+func postForm(ctx context.Context, endpoint string, form neturl.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return util.HTTPClient.Do(req)
+}
+
+// UserStatus describes a user stored in prefs.
+type UserStatus struct {
+	// User is empty for the credentials in the environment, which don't say whose they are.
+	User     string
+	Instance string
+	HasToken bool
+	Default  bool
+}
+
+// Users returns every user stored in prefs, sorted by name.
+// With credentials from the environment, only they can be used, so they're returned instead.
+func Users() ([]UserStatus, error) {
+	creds, err := credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	if usesEnvCredentials() {
+		return []UserStatus{envUser(creds)}, nil
+	}
+
+	prefs, err := util.LoadPrefs()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]UserStatus, 0, len(prefs.Users))
+	for user, prefsUser := range prefs.Users {
+		_, err := creds.Get(keyringServiceAccessToken, user)
+		if err != nil && !errors.Is(err, ErrCredentialNotFound) {
+			slog.Warn("couldn't check for access token", "user", user, "error", err)
+		}
+		users = append(users, UserStatus{
+			User:     user,
+			Instance: prefsUser.Instance,
+			HasToken: err == nil,
+			Default:  user == prefs.DefaultUser,
+		})
+	}
+	slices.SortFunc(users, func(a, b UserStatus) int {
+		return strings.Compare(a.User, b.User)
+	})
+
+	return users, nil
+}
+
+// envUser describes the credentials in the environment, which belong to whoever FEMOJI_TOKEN was issued to.
+func envUser(creds CredentialStore) UserStatus {
+	instance := os.Getenv(envInstance)
+	if normalized, err := util.NormalizeInstance(instance); err == nil {
+		instance = normalized
+	}
+	_, err := creds.Get(keyringServiceAccessToken, "")
+	return UserStatus{
+		Instance: instance,
+		HasToken: err == nil,
+		Default:  true,
+	}
+}
+
+// List prints every stored user with their instance and whether we have a token for them.
+func List() error {
+	users, err := Users()
+	if err != nil {
+		return err
+	}
+
+	if len(users) == 0 {
+		slog.Info("no users stored (log in first)")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "DEFAULT\tUSER\tINSTANCE\tTOKEN")
+	for _, user := range users {
+		isDefault := ""
+		if user.Default {
+			isDefault = "*"
+		}
+		token := "missing"
+		if user.HasToken {
+			token = "stored"
+		}
+		name := user.User
+		if name == "" {
+			name = "(environment)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", isDefault, name, user.Instance, token)
+	}
+
+	return errors.WithStack(w.Flush())
+}
+
+// Switch makes a stored user the default.
+func Switch(user string) error {
+	if _, err := util.GetUserInstance(user); err != nil {
+		slog.Error("unknown user (log in first)", "user", user)
+		return err
+	}
+
+	err := util.SetDefaultUser(user)
 	if err != nil {
-		slog.Error("no user provided, couldn't get default user from prefs (have you logged in before?)")
+		slog.Error("couldn't set default user in prefs", "user", user, "error", err)
 		return err
 	}
 
-	println(user)
+	slog.Info("default user set", "user", user)
 
 	return nil
 }
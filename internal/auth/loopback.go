@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	loopbackHost     = "127.0.0.1"
+	loopbackPath     = "/callback"
+	loopbackTimeout  = 5 * time.Minute
+	loopbackResponse = `<!DOCTYPE html>
+<html><head><title>femoji</title></head>
+<body><p>%s You can close this window and return to femoji.</p></body></html>
+`
+)
+
+// loopbackServer is a temporary HTTP listener that receives the OAuth2 authorization code
+// via a redirect to http://127.0.0.1:<port>/callback.
+type loopbackServer struct {
+	listener    net.Listener
+	server      *http.Server
+	redirectURI string
+	state       string
+	result      chan loopbackResult
+}
+
+type loopbackResult struct {
+	code string
+	err  error
+}
+
+// startLoopbackServer listens on the given port, or on any free port if that isn't available.
+func startLoopbackServer(preferredPort string, state string) (*loopbackServer, error) {
+	var listener net.Listener
+	var err error
+	if preferredPort != "" {
+		listener, err = net.Listen("tcp", net.JoinHostPort(loopbackHost, preferredPort))
+		if err != nil {
+			slog.Debug("preferred loopback port unavailable, picking another", "port", preferredPort, "error", err)
+		}
+	}
+	if listener == nil {
+		listener, err = net.Listen("tcp", net.JoinHostPort(loopbackHost, "0"))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	s := &loopbackServer{
+		listener: listener,
+		redirectURI: (&neturl.URL{
+			Scheme: "http",
+			Host:   listener.Addr().String(),
+			Path:   loopbackPath,
+		}).String(),
+		state:  state,
+		result: make(chan loopbackResult, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(loopbackPath, s.handleCallback)
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.deliver(loopbackResult{err: errors.WithStack(err)})
+		}
+	}()
+
+	return s, nil
+}
+
+func (s *loopbackServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(s.state)) != 1 {
+		http.Error(w, "state mismatch", http.StatusBadRequest)
+		s.deliver(loopbackResult{err: errors.New("OAuth2 state parameter didn't match, refusing authorization code")})
+		return
+	}
+
+	if oauthErr := query.Get("error"); oauthErr != "" {
+		description := query.Get("error_description")
+		if description == "" {
+			description = oauthErr
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprintf(w, loopbackResponse, "Authorization failed.")
+		s.deliver(loopbackResult{err: errors.New(description)})
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, "missing code", http.StatusBadRequest)
+		s.deliver(loopbackResult{err: errors.New("OAuth2 redirect didn't include an authorization code")})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = fmt.Fprintf(w, loopbackResponse, "Authorization successful.")
+	s.deliver(loopbackResult{code: code})
+}
+
+// deliver passes on the first result and drops any after it.
+func (s *loopbackServer) deliver(result loopbackResult) {
+	select {
+	case s.result <- result:
+	default:
+	}
+}
+
+// wait blocks until the authorization code arrives, the timeout expires, or ctx is cancelled.
+func (s *loopbackServer) wait(ctx context.Context) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case result := <-s.result:
+		return result.code, result.err
+	case <-time.After(loopbackTimeout):
+		return "", errors.New("timed out waiting for OAuth2 authorization")
+	}
+}
+
+func (s *loopbackServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = s.server.Shutdown(ctx)
+}
+
+// loopbackPort returns the port of the first loopback redirect URI in the list, if there is one.
+func loopbackPort(redirectURIs []string) string {
+	for _, redirectURI := range redirectURIs {
+		url, err := neturl.Parse(redirectURI)
+		if err != nil {
+			continue
+		}
+		if url.Scheme == "http" && url.Hostname() == loopbackHost && url.Path == loopbackPath {
+			return url.Port()
+		}
+	}
+	return ""
+}
+
+// randomToken returns a URL-safe random string suitable for OAuth2 state and PKCE verifiers.
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// pkceChallenge derives an S256 PKCE code challenge from a verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/zalando/go-keyring"
+
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// CredentialStore holds secrets such as access tokens and client secrets,
+// keyed by a service name and an account (a user or an instance).
+type CredentialStore interface {
+	Get(service string, account string) (string, error)
+	Set(service string, account string, secret string) error
+	Delete(service string, account string) error
+}
+
+// Names of the available credential stores.
+const (
+	StoreAuto    = "auto"
+	StoreKeyring = "keyring"
+	StoreFile    = "file"
+	StoreEnv     = "env"
+)
+
+var StoreNames = []string{StoreAuto, StoreKeyring, StoreFile, StoreEnv}
+
+// ErrCredentialNotFound is returned by every store when a secret doesn't exist.
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// ErrCredentialStoreReadOnly is returned when trying to modify a store that can't be written to.
+var ErrCredentialStoreReadOnly = errors.New("credential store is read-only")
+
+// Environment variables read by the env store.
+const (
+	envToken        = "FEMOJI_TOKEN"
+	envInstance     = "FEMOJI_INSTANCE"
+	envClientSecret = "FEMOJI_CLIENT_SECRET"
+)
+
+// storeOverride is the store selected for this invocation, taking precedence over prefs.
+var storeOverride string
+
+// store is the resolved credential store, cached after first use.
+var store CredentialStore
+
+// SetCredentialStore selects a credential store for this invocation, overriding prefs.
+func SetCredentialStore(name string) error {
+	if name != "" && !isStoreName(name) {
+		return errors.Errorf("unknown credential store: %s", name)
+	}
+	storeOverride = name
+	store = nil
+	return nil
+}
+
+func isStoreName(name string) bool {
+	for _, storeName := range StoreNames {
+		if name == storeName {
+			return true
+		}
+	}
+	return false
+}
+
+// credentials returns the credential store selected by flag, prefs, or auto-detection.
+func credentials() (CredentialStore, error) {
+	if store != nil {
+		return store, nil
+	}
+
+	name := storeOverride
+	if name == "" {
+		var err error
+		name, err = util.GetCredentialStore()
+		if err != nil && !errors.Is(err, util.PrefNotFound) {
+			return nil, err
+		}
+	}
+
+	var err error
+	store, err = newCredentialStore(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func newCredentialStore(name string) (CredentialStore, error) {
+	switch name {
+	case StoreKeyring:
+		return keyringStore{}, nil
+	case StoreFile:
+		return newFileStore(), nil
+	case StoreEnv:
+		return envStore{}, nil
+	case StoreAuto, "":
+		return detectCredentialStore(), nil
+	default:
+		return nil, errors.Errorf("unknown credential store: %s", name)
+	}
+}
+
+// detectCredentialStore prefers the environment if a token is set there,
+// then the system keyring, then the encrypted file if there's no usable keyring.
+func detectCredentialStore() CredentialStore {
+	if os.Getenv(envToken) != "" && os.Getenv(envInstance) != "" {
+		slog.Debug("using credentials from environment")
+		return envStore{}
+	}
+
+	_, err := keyring.Get(keyringServiceAccessToken, "")
+	if err == nil || errors.Is(err, keyring.ErrNotFound) {
+		return keyringStore{}
+	}
+
+	slog.Debug("system keyring unavailable, using encrypted credentials file", "error", err)
+	return newFileStore()
+}
+
+// usesEnvCredentials returns true if credentials, including the instance, come from the environment.
+func usesEnvCredentials() bool {
+	s, err := credentials()
+	if err != nil {
+		return false
+	}
+	_, ok := s.(envStore)
+	return ok
+}
+
+// keyringStore keeps secrets in the OS keychain.
+type keyringStore struct{}
+
+func (keyringStore) Get(service string, account string) (string, error) {
+	secret, err := keyring.Get(service, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", errors.WithStack(ErrCredentialNotFound)
+	}
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return secret, nil
+}
+
+func (keyringStore) Set(service string, account string, secret string) error {
+	return errors.WithStack(keyring.Set(service, account, secret))
+}
+
+func (keyringStore) Delete(service string, account string) error {
+	err := keyring.Delete(service, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return errors.WithStack(ErrCredentialNotFound)
+	}
+	return errors.WithStack(err)
+}
+
+// envStore reads a single user's access token from the environment.
+// It's intended for CI and cron jobs, and can't be written to.
+type envStore struct{}
+
+func (envStore) Get(service string, account string) (string, error) {
+	var secret string
+	switch service {
+	case keyringServiceAccessToken:
+		secret = os.Getenv(envToken)
+	case keyringServiceClientSecret:
+		secret = os.Getenv(envClientSecret)
+	}
+	if secret == "" {
+		return "", errors.WithStack(ErrCredentialNotFound)
+	}
+	return secret, nil
+}
+
+func (envStore) Set(service string, account string, secret string) error {
+	return errors.WithStack(ErrCredentialStoreReadOnly)
+}
+
+func (envStore) Delete(service string, account string) error {
+	return errors.WithStack(ErrCredentialStoreReadOnly)
+}
+
+// SetDefaultCredentialStore saves the credential store to use when none is given on the command line.
+func SetDefaultCredentialStore(name string) error {
+	if !isStoreName(name) {
+		return errors.Errorf("unknown credential store: %s", name)
+	}
+
+	err := util.SetCredentialStore(name)
+	if err != nil {
+		slog.Error("couldn't set credential store in prefs", "store", name, "error", err)
+		return err
+	}
+
+	slog.Info("default credential store set", "store", name)
+
+	return nil
+}
+
+// ShowCredentialStore prints the credential store currently in use.
+func ShowCredentialStore() error {
+	s, err := credentials()
+	if err != nil {
+		return err
+	}
+
+	switch s.(type) {
+	case keyringStore:
+		println(StoreKeyring)
+	case *fileStore:
+		println(StoreFile)
+	case envStore:
+		println(StoreEnv)
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// envPassphrase optionally supplies the passphrase the credentials file is encrypted with.
+// Without it, a random key is generated and kept next to the credentials file.
+const envPassphrase = "FEMOJI_CREDENTIALS_PASSPHRASE"
+
+const (
+	fileStoreVersion = 1
+	fileStoreKeySize = 32
+)
+
+// fileStore keeps secrets in an AES-GCM encrypted file in the femoji config dir,
+// for systems without a usable keyring.
+type fileStore struct {
+	path    string
+	keyPath string
+}
+
+// fileStoreEnvelope is the on-disk format of the credentials file.
+type fileStoreEnvelope struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// fileStoreSecrets maps service to account to secret.
+type fileStoreSecrets map[string]map[string]string
+
+func newFileStore() *fileStore {
+	return &fileStore{
+		path:    util.ConfigPath("credentials.enc"),
+		keyPath: util.ConfigPath("credentials.key"),
+	}
+}
+
+func (s *fileStore) Get(service string, account string) (string, error) {
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+
+	secret, exists := secrets[service][account]
+	if !exists {
+		return "", errors.WithStack(ErrCredentialNotFound)
+	}
+
+	return secret, nil
+}
+
+func (s *fileStore) Set(service string, account string, secret string) error {
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if secrets[service] == nil {
+		secrets[service] = map[string]string{}
+	}
+	secrets[service][account] = secret
+
+	return s.save(secrets)
+}
+
+func (s *fileStore) Delete(service string, account string) error {
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if _, exists := secrets[service][account]; !exists {
+		return errors.WithStack(ErrCredentialNotFound)
+	}
+	delete(secrets[service], account)
+
+	return s.save(secrets)
+}
+
+// load decrypts the credentials file, returning no secrets if it doesn't exist yet.
+func (s *fileStore) load() (fileStoreSecrets, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return fileStoreSecrets{}, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var envelope fileStoreEnvelope
+	err = json.Unmarshal(data, &envelope)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if envelope.Version != fileStoreVersion {
+		return nil, errors.Errorf("unsupported credentials file version: %d", envelope.Version)
+	}
+
+	aead, err := s.aead(envelope.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't decrypt credentials file (wrong passphrase or key?)")
+	}
+
+	var secrets fileStoreSecrets
+	err = json.Unmarshal(plaintext, &secrets)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if secrets == nil {
+		secrets = fileStoreSecrets{}
+	}
+
+	return secrets, nil
+}
+
+// save encrypts and writes the credentials file, replacing the previous one atomically.
+func (s *fileStore) save(secrets fileStoreSecrets) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var salt []byte
+	if os.Getenv(envPassphrase) != "" {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	aead, err := s.aead(salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.WithStack(err)
+	}
+
+	data, err := json.Marshal(fileStoreEnvelope{
+		Version:    fileStoreVersion,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return writeFilePrivate(s.path, data)
+}
+
+// aead returns the cipher for the credentials file.
+// If the file has a salt, the key is derived from the passphrase; otherwise the key file is used.
+func (s *fileStore) aead(salt []byte) (cipher.AEAD, error) {
+	var key []byte
+	var err error
+	if len(salt) > 0 {
+		passphrase := os.Getenv(envPassphrase)
+		if passphrase == "" {
+			return nil, errors.Errorf("credentials file is passphrase-protected, set %s", envPassphrase)
+		}
+		key, err = scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, fileStoreKeySize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	} else {
+		key, err = s.ensureKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return aead, nil
+}
+
+// ensureKey reads the random key from the key file, creating it if necessary.
+func (s *fileStore) ensureKey() ([]byte, error) {
+	key, err := os.ReadFile(s.keyPath)
+	if err == nil {
+		if len(key) != fileStoreKeySize {
+			return nil, errors.Errorf("credentials key file has wrong size: %s", s.keyPath)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+
+	key = make([]byte, fileStoreKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	err = writeFilePrivate(s.keyPath, key)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("created credentials key file; set a passphrase for stronger protection", "path", s.keyPath, "env", envPassphrase)
+
+	return key, nil
+}
+
+// writeFilePrivate writes a file readable only by the current user, via a temp file and rename.
+func writeFilePrivate(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	err = f.Chmod(0o600)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.Rename(f.Name(), path))
+}
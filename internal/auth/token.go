@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-openapi/runtime"
+	"github.com/pkg/errors"
+
+	"github.com/CDN18/femoji-cli/internal/util"
+	"github.com/owu-one/gotosocial-sdk/client/admin"
+)
+
+// ImportToken stores an existing access token for a user, read from r,
+// as an alternative to the browser login flow.
+// If instance is empty, it's found by WebFinger lookup.
+// verify is called with a client using the token, and should fail if the token doesn't work.
+func ImportToken(ctx context.Context, user string, instance string, r io.Reader, verify func(context.Context, *Client) error) error {
+	err := validateUser(user)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan()
+	if err := scanner.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	accessToken := strings.TrimSpace(scanner.Text())
+	if accessToken == "" {
+		return errors.WithStack(errors.New("no access token given on stdin"))
+	}
+
+	if instance == "" {
+		instance, err = findInstance(user)
+		if err != nil {
+			slog.Error("WebFinger lookup failed (pass --instance to skip it)", "user", user, "error", err)
+			return err
+		}
+	} else {
+		instance, err = util.NormalizeInstance(instance)
+		if err != nil {
+			return err
+		}
+	}
+
+	client, err := newClient(user, instance, accessToken)
+	if err != nil {
+		return err
+	}
+
+	err = verify(ctx, client)
+	if err != nil {
+		slog.Error("access token didn't work", "user", user, "instance", instance, "error", err)
+		return err
+	}
+
+	err = checkAdminAccess(ctx, client, accessToken)
+	if err != nil {
+		slog.Error("access token doesn't have admin rights", "user", user, "instance", instance, "error", err)
+		return err
+	}
+
+	creds, err := credentials()
+	if err != nil {
+		return err
+	}
+
+	err = creds.Set(keyringServiceAccessToken, user, accessToken)
+	if err != nil {
+		slog.Error("couldn't store access token", "user", user, "instance", instance, "error", err)
+		return err
+	}
+
+	err = util.SetUserInstance(user, instance)
+	if err != nil {
+		slog.Error("couldn't set instance in prefs", "user", user, "instance", instance, "error", err)
+		return err
+	}
+
+	err = util.SetDefaultUser(user)
+	if err != nil {
+		slog.Error("couldn't set default user in prefs", "user", user, "instance", instance, "error", err)
+		return err
+	}
+
+	slog.Info("access token imported", "user", user, "instance", instance)
+
+	return nil
+}
+
+// checkAdminAccess confirms that a token was granted admin scopes.
+// Servers that report a token's scopes are asked directly;
+// for the rest, we try an admin API call and see whether it's allowed.
+func checkAdminAccess(ctx context.Context, client *Client, accessToken string) error {
+	scopes, err := tokenScopes(ctx, client.Instance, accessToken)
+	if err == nil {
+		if !hasAdminScopes(scopes) {
+			return errors.Errorf("token has scopes %q, but admin scopes are required", strings.Join(scopes, " "))
+		}
+		return nil
+	}
+	slog.Debug("couldn't get token scopes, probing admin API instead", "instance", client.Instance, "error", err)
+
+	_, err = client.Client.Admin.EmojiCategoriesGet(
+		admin.NewEmojiCategoriesGetParamsWithContext(ctx),
+		admin.ClientOption(func(op *runtime.ClientOperation) {
+			op.AuthInfo = client.Auth
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "admin API call failed")
+	}
+
+	return nil
+}
+
+// hasAdminScopes returns true if the scopes include read and write access to the admin API.
+func hasAdminScopes(scopes []string) bool {
+	if slices.Contains(scopes, "admin") {
+		return true
+	}
+	return slices.Contains(scopes, "admin:read") && slices.Contains(scopes, "admin:write")
+}
+
+// tokenScopes asks the instance which scopes an access token was granted.
+// Not every server reports this, in which case an error is returned.
+func tokenScopes(ctx context.Context, instance string, accessToken string) ([]string, error) {
+	appVerifyURL, err := util.InstanceURL(instance, "/api/v1/apps/verify_credentials")
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, appVerifyURL, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := util.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("app verify endpoint returned status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Scopes []string `json:"scopes"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&payload)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if payload.Scopes == nil {
+		return nil, errors.New("app verify endpoint didn't report scopes")
+	}
+
+	return payload.Scopes, nil
+}
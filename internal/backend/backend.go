@@ -0,0 +1,151 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/go-openapi/runtime"
+	"github.com/owu-one/gotosocial-sdk/models"
+
+	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// Backend reads custom emojis from an instance.
+type Backend interface {
+	// Name is the software family this backend speaks to, e.g. "mastodon".
+	Name() string
+	// ListEmojis returns every custom emoji on the instance.
+	ListEmojis(ctx context.Context) ([]*Emoji, error)
+	// ListCategories returns the instance's emoji categories. IDs may be empty if the API doesn't have them.
+	ListCategories(ctx context.Context) ([]*models.EmojiCategory, error)
+	// FetchEmoji starts downloading an emoji's image, with optional extra request headers.
+	// The caller must close the response body.
+	FetchEmoji(ctx context.Context, emoji *Emoji, header http.Header) (*http.Response, error)
+}
+
+// EmojiManager is implemented by backends that can modify an instance's emojis.
+type EmojiManager interface {
+	// ListLocalEmojis returns the instance's own emojis, including admin-only fields such as IDs.
+	ListLocalEmojis(ctx context.Context) ([]*models.AdminEmoji, error)
+	CreateEmoji(ctx context.Context, shortcode string, category string, image runtime.NamedReadCloser) error
+	// UpdateEmoji changes an emoji's image and/or category. Nil arguments are left as they are.
+	UpdateEmoji(ctx context.Context, id string, category *string, image runtime.NamedReadCloser) error
+	DeleteEmoji(ctx context.Context, id string) error
+}
+
+// Family describes a group of server software that share an emoji API.
+type Family struct {
+	// Name is used to select the family with --software.
+	Name string
+	// Software lists NodeInfo software names belonging to this family.
+	Software []string
+	// New creates a backend for a remote instance. nodeinfo is nil if the family was selected by name.
+	New func(instance string, nodeinfo *util.NodeInfo) Backend
+}
+
+// Families lists every supported software family. Add new servers here.
+var Families = []Family{
+	{
+		Name:     "mastodon",
+		Software: []string{"mastodon", "gotosocial", "hometown"},
+		New:      newMastodon,
+	},
+	{
+		Name:     "pleroma",
+		Software: []string{"pleroma", "akkoma"},
+		New:      newPleroma,
+	},
+	{
+		Name:     "misskey",
+		Software: []string{"misskey", "firefish", "iceshrimp", "sharkey", "catodon", "foundkey"},
+		New:      newMisskey,
+	},
+}
+
+// Auto selects a family by NodeInfo detection.
+const Auto = "auto"
+
+// FamilyNames returns the names that can be passed to ForInstance.
+func FamilyNames() []string {
+	names := []string{Auto}
+	for _, family := range Families {
+		names = append(names, family.Name)
+	}
+	return names
+}
+
+// ForInstance returns a backend for reading a remote instance's public emoji API.
+// software is a family name, or Auto to detect it from NodeInfo.
+func ForInstance(ctx context.Context, instance string, software string) (Backend, error) {
+	instance, err := util.NormalizeInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	if software != Auto && software != "" {
+		for _, family := range Families {
+			if family.Name == software {
+				return family.New(instance, nil), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid instance type: %s", software)
+	}
+
+	nodeinfo, err := util.GetNodeInfo(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, family := range Families {
+		if slices.Contains(family.Software, nodeinfo.Software.Name) {
+			slog.Info("detected instance software", "instance", instance, "software", nodeinfo.Software.Name, "version", nodeinfo.Software.Version, "family", family.Name)
+			return family.New(instance, nodeinfo), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown instance type: %s", nodeinfo.Software.Name)
+}
+
+// ForClient returns a backend for the logged-in user's own instance, using their credentials.
+// It can manage emojis as well as read them.
+func ForClient(authClient *auth.Client) *GoToSocial {
+	return &GoToSocial{authClient: authClient}
+}
+
+// fetchURL downloads an emoji image from wherever the instance says it's hosted.
+func fetchURL(ctx context.Context, emoji *Emoji, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, emoji.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return util.HTTPClient.Do(req)
+}
+
+func get(ctx context.Context, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	return util.HTTPClient.Do(req)
+}
+
+// categoriesFromEmojis collects the distinct categories of a list of emojis, in order of first appearance.
+func categoriesFromEmojis(emojis []*Emoji) []*models.EmojiCategory {
+	var categories []*models.EmojiCategory
+	seen := map[string]bool{}
+	for _, emoji := range emojis {
+		if emoji.Category == "" || seen[emoji.Category] {
+			continue
+		}
+		seen[emoji.Category] = true
+		categories = append(categories, &models.EmojiCategory{Name: emoji.Category})
+	}
+	return categories
+}
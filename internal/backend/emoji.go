@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"github.com/owu-one/gotosocial-sdk/models"
+
+	"github.com/CDN18/femoji-cli/internal/filter"
+)
+
+// Emoji is femoji's own record of a custom emoji.
+// It carries the metadata of every backend we support, so that nothing is lost between download and upload.
+// The JSON field names of the fields shared with the Mastodon API match it, so older index files still load.
+type Emoji struct {
+	Shortcode       string `json:"shortcode"`
+	Category        string `json:"category,omitempty"`
+	URL             string `json:"url"`
+	StaticURL       string `json:"static_url,omitempty"`
+	VisibleInPicker bool   `json:"visible_in_picker"`
+	// Aliases are alternative names used to search for the emoji (Misskey).
+	Aliases []string `json:"aliases,omitempty"`
+	// Sensitive emojis are hidden behind a warning (Misskey).
+	Sensitive bool `json:"sensitive,omitempty"`
+	// LocalOnly emojis aren't federated (Misskey).
+	LocalOnly bool `json:"local_only,omitempty"`
+	// License is free-form attribution or licensing text (Misskey, Pleroma).
+	License string `json:"license,omitempty"`
+	// RoleIDs restricts use of the emoji as a reaction to the given roles (Misskey).
+	RoleIDs []string `json:"role_ids,omitempty"`
+}
+
+// emojiFromModel converts an emoji from the Mastodon API.
+func emojiFromModel(emoji *models.Emoji) *Emoji {
+	return &Emoji{
+		Shortcode:       emoji.Shortcode,
+		Category:        emoji.Category,
+		URL:             emoji.URL,
+		StaticURL:       emoji.StaticURL,
+		VisibleInPicker: emoji.VisibleInPicker,
+	}
+}
+
+func emojisFromModels(emojis []*models.Emoji) []*Emoji {
+	converted := make([]*Emoji, 0, len(emojis))
+	for _, emoji := range emojis {
+		converted = append(converted, emojiFromModel(emoji))
+	}
+	return converted
+}
+
+// FilterEmojis returns the emojis whose shortcode and category match f.
+func FilterEmojis(emojis []*Emoji, f *filter.Filter) []*Emoji {
+	var filtered []*Emoji
+	for _, emoji := range emojis {
+		if f.Match(emoji.Shortcode, emoji.Category) {
+			filtered = append(filtered, emoji)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,126 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-openapi/runtime"
+	"github.com/owu-one/gotosocial-sdk/client/admin"
+	"github.com/owu-one/gotosocial-sdk/client/custom_emojis"
+	"github.com/owu-one/gotosocial-sdk/models"
+	"github.com/pkg/errors"
+
+	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// GoToSocial uses the logged-in user's credentials with the GtS API,
+// which includes the admin emoji API for managing emojis.
+type GoToSocial struct {
+	authClient *auth.Client
+}
+
+func (b *GoToSocial) Name() string {
+	return "gotosocial"
+}
+
+func (b *GoToSocial) ListEmojis(ctx context.Context) ([]*Emoji, error) {
+	resp, err := b.authClient.Client.CustomEmojis.CustomEmojisGet(custom_emojis.NewCustomEmojisGetParamsWithContext(ctx), b.authClient.Auth)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return emojisFromModels(resp.GetPayload()), nil
+}
+
+func (b *GoToSocial) ListCategories(ctx context.Context) ([]*models.EmojiCategory, error) {
+	resp, err := b.authClient.Client.Admin.EmojiCategoriesGet(admin.NewEmojiCategoriesGetParamsWithContext(ctx), b.withAuth())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return resp.GetPayload(), nil
+}
+
+func (b *GoToSocial) FetchEmoji(ctx context.Context, emoji *Emoji, header http.Header) (*http.Response, error) {
+	return fetchURL(ctx, emoji, header)
+}
+
+func (b *GoToSocial) ListLocalEmojis(ctx context.Context) ([]*models.AdminEmoji, error) {
+	resp, err := b.authClient.Client.Admin.EmojisGet(
+		&admin.EmojisGetParams{
+			Filter:  util.Ptr("domain:local"),
+			Limit:   util.Ptr(int64(0)),
+			Context: ctx,
+		},
+		b.withAuth(),
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return resp.GetPayload(), nil
+}
+
+func (b *GoToSocial) CreateEmoji(ctx context.Context, shortcode string, category string, image runtime.NamedReadCloser) error {
+	_, err := b.authClient.Client.Admin.EmojiCreate(
+		&admin.EmojiCreateParams{
+			Category:  util.Ptr(category),
+			Image:     image,
+			Shortcode: shortcode,
+			Context:   ctx,
+		},
+		b.authClient.Auth,
+		multipart,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (b *GoToSocial) UpdateEmoji(ctx context.Context, id string, category *string, image runtime.NamedReadCloser) error {
+	_, err := b.authClient.Client.Admin.EmojiUpdate(
+		&admin.EmojiUpdateParams{
+			Type:     "modify",
+			ID:       id,
+			Category: category,
+			Image:    image,
+			Context:  ctx,
+		},
+		b.authClient.Auth,
+		multipart,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (b *GoToSocial) DeleteEmoji(ctx context.Context, id string) error {
+	_, err := b.authClient.Client.Admin.EmojiDelete(
+		&admin.EmojiDeleteParams{
+			ID:      id,
+			Context: ctx,
+		},
+		b.authClient.Auth,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// withAuth attaches credentials to admin API calls that don't take them as an argument.
+func (b *GoToSocial) withAuth() admin.ClientOption {
+	return func(op *runtime.ClientOperation) {
+		op.AuthInfo = b.authClient.Auth
+	}
+}
+
+func multipart(op *runtime.ClientOperation) {
+	op.ConsumesMediaTypes = []string{"multipart/form-data"}
+}
@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/owu-one/gotosocial-sdk/models"
+
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// Mastodon reads the public custom emoji API shared by Mastodon and its relatives.
+type Mastodon struct {
+	instance string
+}
+
+func newMastodon(instance string, _ *util.NodeInfo) Backend {
+	return &Mastodon{instance: instance}
+}
+
+func (b *Mastodon) Name() string {
+	return "mastodon"
+}
+
+func (b *Mastodon) ListEmojis(ctx context.Context) ([]*Emoji, error) {
+	endpoint, err := util.InstanceURL(b.instance, "/api/v1/custom_emojis")
+	if err != nil {
+		return nil, err
+	}
+
+	var emojis []*models.Emoji
+	if err := util.GetJSON(ctx, endpoint, &emojis); err != nil {
+		return nil, err
+	}
+
+	return emojisFromModels(emojis), nil
+}
+
+func (b *Mastodon) ListCategories(ctx context.Context) ([]*models.EmojiCategory, error) {
+	emojis, err := b.ListEmojis(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return categoriesFromEmojis(emojis), nil
+}
+
+func (b *Mastodon) FetchEmoji(ctx context.Context, emoji *Emoji, header http.Header) (*http.Response, error) {
+	return fetchURL(ctx, emoji, header)
+}
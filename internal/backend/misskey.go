@@ -0,0 +1,143 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/owu-one/gotosocial-sdk/models"
+
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+type MisskeyResponse struct {
+	Emojis []MisskeyEmoji `json:"emojis"`
+}
+
+type MisskeyEmoji struct {
+	Aliases   []string `json:"aliases"`
+	Name      string   `json:"name"`
+	Category  *string  `json:"category"`
+	URL       string   `json:"url"`
+	LocalOnly bool     `json:"localOnly"`
+	Sensitive bool     `json:"isSensitive"`
+	License   *string  `json:"license"`
+	RoleIds   []string `json:"roleIdsThatCanBeUsedThisEmojiAsReaction"`
+}
+
+// Misskey reads the public emoji API of Misskey and its forks.
+type Misskey struct {
+	instance string
+	nodeinfo *util.NodeInfo
+}
+
+func newMisskey(instance string, nodeinfo *util.NodeInfo) Backend {
+	return &Misskey{instance: instance, nodeinfo: nodeinfo}
+}
+
+func (b *Misskey) Name() string {
+	return "misskey"
+}
+
+func (b *Misskey) ListEmojis(ctx context.Context) ([]*Emoji, error) {
+	var misskeyResp MisskeyResponse
+	var err error
+	if b.isLegacy() {
+		err = b.getLegacyEmojis(ctx, &misskeyResp)
+	} else {
+		err = b.getEmojis(ctx, &misskeyResp)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var emojis []*Emoji
+	for _, me := range misskeyResp.Emojis {
+		category := "uncategorized"
+		if me.Category != nil && *me.Category != "" {
+			category = *me.Category
+		}
+		license := ""
+		if me.License != nil {
+			license = *me.License
+		}
+
+		emojis = append(emojis, &Emoji{
+			Category:        category,
+			Shortcode:       me.Name,
+			URL:             me.URL,
+			VisibleInPicker: true,
+			Aliases:         nonEmpty(me.Aliases),
+			Sensitive:       me.Sensitive,
+			LocalOnly:       me.LocalOnly,
+			License:         license,
+			RoleIDs:         me.RoleIds,
+		})
+	}
+
+	return emojis, nil
+}
+
+// isLegacy returns true for Misskey v12 and earlier, which only list emojis in instance metadata.
+// Forks have their own version numbering, so this only applies to Misskey itself.
+func (b *Misskey) isLegacy() bool {
+	return b.nodeinfo != nil && b.nodeinfo.Software.Name == "misskey" && !b.nodeinfo.SoftwareVersionAtLeast(13)
+}
+
+func (b *Misskey) getEmojis(ctx context.Context, misskeyResp *MisskeyResponse) error {
+	endpoint, err := util.InstanceURL(b.instance, "/api/emojis")
+	if err != nil {
+		return err
+	}
+	return util.GetJSON(ctx, endpoint, misskeyResp)
+}
+
+func (b *Misskey) getLegacyEmojis(ctx context.Context, misskeyResp *MisskeyResponse) error {
+	endpoint, err := util.InstanceURL(b.instance, "/api/meta")
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader([]byte(`{"detail":true}`)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := util.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(misskeyResp)
+}
+
+func (b *Misskey) ListCategories(ctx context.Context) ([]*models.EmojiCategory, error) {
+	emojis, err := b.ListEmojis(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return categoriesFromEmojis(emojis), nil
+}
+
+func (b *Misskey) FetchEmoji(ctx context.Context, emoji *Emoji, header http.Header) (*http.Response, error) {
+	return fetchURL(ctx, emoji, header)
+}
+
+// nonEmpty drops empty strings, which Misskey uses for "no aliases".
+func nonEmpty(values []string) []string {
+	var filtered []string
+	for _, value := range values {
+		if value != "" {
+			filtered = append(filtered, value)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,195 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"sort"
+
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// Pleroma reads emojis from Pleroma and Akkoma, which serve the Mastodon emoji API as well as their own pack API.
+type Pleroma struct {
+	Mastodon
+}
+
+func newPleroma(instance string, _ *util.NodeInfo) Backend {
+	return &Pleroma{Mastodon: Mastodon{instance: instance}}
+}
+
+func (b *Pleroma) Name() string {
+	return "pleroma"
+}
+
+// Pack is a named group of emojis with its own metadata, as used by Pleroma and Akkoma.
+type Pack struct {
+	Name string
+	// Metadata is the pack's "pack" object, kept as-is so it can be written back out unchanged.
+	Metadata json.RawMessage
+	// Files maps shortcodes to file paths relative to the pack directory.
+	Files map[string]string
+	// Info is the part of Metadata we use ourselves.
+	Info PackInfo
+}
+
+type PackInfo struct {
+	Description string `json:"description,omitempty"`
+	License     string `json:"license,omitempty"`
+	Homepage    string `json:"homepage,omitempty"`
+	ShareFiles  bool   `json:"share-files,omitempty"`
+	CanDownload bool   `json:"can-download,omitempty"`
+}
+
+// PackFile is the pack.json format Pleroma uses for packs on disk.
+type PackFile struct {
+	Pack  json.RawMessage   `json:"pack"`
+	Files map[string]string `json:"files"`
+}
+
+// PackSource is implemented by backends that group emojis into packs.
+type PackSource interface {
+	ListPacks(ctx context.Context) ([]*Pack, error)
+	// FetchPackArchive starts downloading a zip of a shareable pack. The caller must close the response body.
+	FetchPackArchive(ctx context.Context, pack *Pack) (*http.Response, error)
+	// FetchPackFile starts downloading a single file from a pack. The caller must close the response body.
+	FetchPackFile(ctx context.Context, pack *Pack, file string) (*http.Response, error)
+}
+
+const pleromaPageSize = 50
+
+type pleromaPack struct {
+	Files      map[string]string `json:"files"`
+	FilesCount int               `json:"files_count"`
+	Pack       json.RawMessage   `json:"pack"`
+}
+
+type pleromaPacks struct {
+	Count int                    `json:"count"`
+	Packs map[string]pleromaPack `json:"packs"`
+}
+
+func (b *Pleroma) ListPacks(ctx context.Context) ([]*Pack, error) {
+	var packs []*Pack
+	for page := 1; ; page++ {
+		endpoint, err := b.apiURL("/api/v1/pleroma/emoji/packs", neturl.Values{
+			"page":      []string{fmt.Sprint(page)},
+			"page_size": []string{fmt.Sprint(pleromaPageSize)},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var resp pleromaPacks
+		if err := util.GetJSON(ctx, endpoint, &resp); err != nil {
+			return nil, err
+		}
+
+		names := make([]string, 0, len(resp.Packs))
+		for name := range resp.Packs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			pack, err := b.completePack(ctx, name, resp.Packs[name])
+			if err != nil {
+				return nil, err
+			}
+			packs = append(packs, pack)
+		}
+
+		if len(resp.Packs) == 0 || len(packs) >= resp.Count {
+			break
+		}
+	}
+
+	return packs, nil
+}
+
+// completePack fetches the rest of a pack's file list if the pack listing didn't include all of it.
+func (b *Pleroma) completePack(ctx context.Context, name string, listed pleromaPack) (*Pack, error) {
+	files := listed.Files
+	if files == nil {
+		files = map[string]string{}
+	}
+
+	// The listing holds the first page of files, so carry on from the second page at the same size.
+	pageSize, firstPage := pleromaPageSize, 1
+	if len(files) > 0 {
+		pageSize, firstPage = len(files), 2
+	}
+	// Stop after the last page there should be, in case the server ignores paging and repeats itself.
+	lastPage := (listed.FilesCount + pageSize - 1) / pageSize
+	for page := firstPage; page <= lastPage && len(files) < listed.FilesCount; page++ {
+		endpoint, err := b.apiURL("/api/v1/pleroma/emoji/pack", neturl.Values{
+			"name":      []string{name},
+			"page":      []string{fmt.Sprint(page)},
+			"page_size": []string{fmt.Sprint(pageSize)},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var resp pleromaPack
+		if err := util.GetJSON(ctx, endpoint, &resp); err != nil {
+			return nil, err
+		}
+		added := 0
+		for shortcode, file := range resp.Files {
+			if _, exists := files[shortcode]; !exists {
+				added++
+			}
+			files[shortcode] = file
+		}
+		if added == 0 {
+			break
+		}
+	}
+	if len(files) < listed.FilesCount {
+		slog.Warn("couldn't get every file in pack", "pack", name, "files", len(files), "files_count", listed.FilesCount)
+	}
+
+	pack := &Pack{
+		Name:     name,
+		Metadata: listed.Pack,
+		Files:    files,
+	}
+	if len(listed.Pack) > 0 {
+		if err := json.Unmarshal(listed.Pack, &pack.Info); err != nil {
+			return nil, err
+		}
+	}
+
+	return pack, nil
+}
+
+func (b *Pleroma) FetchPackArchive(ctx context.Context, pack *Pack) (*http.Response, error) {
+	endpoint, err := b.apiURL("/api/v1/pleroma/emoji/packs/archive", neturl.Values{
+		"name": []string{pack.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return get(ctx, endpoint)
+}
+
+func (b *Pleroma) FetchPackFile(ctx context.Context, pack *Pack, file string) (*http.Response, error) {
+	endpoint, err := util.InstanceURL(b.instance, path.Join("/emoji", pack.Name, file))
+	if err != nil {
+		return nil, err
+	}
+	return get(ctx, endpoint)
+}
+
+func (b *Pleroma) apiURL(apiPath string, query neturl.Values) (string, error) {
+	endpoint, err := util.InstanceURL(b.instance, apiPath)
+	if err != nil {
+		return "", err
+	}
+	return endpoint + "?" + query.Encode(), nil
+}
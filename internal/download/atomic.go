@@ -0,0 +1,167 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// tempSuffix marks partially written files so they can be found and removed after an interrupted run.
+const tempSuffix = ".femoji-tmp"
+
+// writeAtomic streams r into a temp file next to filePath, checks it, and renames it into place,
+// so filePath only ever holds a complete file. expectedSize is ignored if negative.
+// It returns the size and SHA-256 of what was written.
+func writeAtomic(filePath string, r io.Reader, expectedSize int64) (int64, string, error) {
+	tmp, err := writeTemp(filepath.Dir(filePath), filepath.Base(filePath), r, expectedSize)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := tmp.commit(filePath); err != nil {
+		return 0, "", err
+	}
+	return tmp.size, tmp.sha256, nil
+}
+
+// tempFile is a complete download waiting to be moved into place.
+type tempFile struct {
+	path   string
+	size   int64
+	sha256 string
+}
+
+// writeTemp streams r into a temp file in dir, named after base, and checks it's as long as expected.
+// expectedSize is ignored if negative. Nothing is left behind if it fails.
+func writeTemp(dir string, base string, r io.Reader, expectedSize int64) (*tempFile, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(dir, "."+base+".*"+tempSuffix)
+	if err != nil {
+		return nil, err
+	}
+	tmp := &tempFile{path: f.Name()}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && expectedSize >= 0 && size != expectedSize {
+		err = fmt.Errorf("incomplete download: got %d bytes, expected %d", size, expectedSize)
+	}
+	if err != nil {
+		tmp.discard()
+		return nil, err
+	}
+
+	tmp.size = size
+	tmp.sha256 = hex.EncodeToString(h.Sum(nil))
+	return tmp, nil
+}
+
+// commit checks the file is an image of the type filePath's extension says, and renames it to filePath.
+// The temp file is removed if it isn't.
+func (t *tempFile) commit(filePath string) error {
+	if err := verifyImage(t.path, filepath.Ext(filePath)); err != nil {
+		t.discard()
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		t.discard()
+		return err
+	}
+	if err := os.Rename(t.path, filePath); err != nil {
+		t.discard()
+		return err
+	}
+	return nil
+}
+
+func (t *tempFile) discard() {
+	_ = os.Remove(t.path)
+}
+
+// imageFormats maps file extensions to the format name reported by image.DecodeConfig.
+var imageFormats = map[string]string{
+	".png":  "png",
+	".apng": "png",
+	".gif":  "gif",
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".webp": "webp",
+}
+
+// verifyImage checks that a file is a readable image of the type its extension says.
+// Formats we can't decode are only checked for their signature.
+func verifyImage(path string, ext string) error {
+	ext = strings.ToLower(ext)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch ext {
+	case ".svg":
+		head := make([]byte, util.SniffLength)
+		n, _ := io.ReadFull(f, head)
+		if !util.IsSVG(head[:n]) {
+			return fmt.Errorf("file isn't an SVG image")
+		}
+		return nil
+	case ".avif":
+		head := make([]byte, util.SniffLength)
+		n, _ := io.ReadFull(f, head)
+		if !util.IsAVIF(head[:n]) {
+			return fmt.Errorf("file isn't an AVIF image")
+		}
+		return nil
+	}
+
+	_, format, err := image.DecodeConfig(f)
+	if err != nil {
+		if _, known := imageFormats[ext]; known {
+			return fmt.Errorf("file isn't a valid image: %w", err)
+		}
+		// Unknown extension and undecodable: nothing to check against.
+		return nil
+	}
+
+	if expected, known := imageFormats[ext]; known && format != expected {
+		return fmt.Errorf("file is %s, but its extension says %s", format, expected)
+	}
+
+	return nil
+}
+
+// removeTempFiles deletes partial files left behind by an interrupted run.
+func removeTempFiles(dir string) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), tempSuffix) {
+			if err := os.Remove(path); err == nil {
+				slog.Info("removed partial file from an earlier run", "path", path)
+			}
+		}
+		return nil
+	})
+}
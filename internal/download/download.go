@@ -1,9 +1,8 @@
 package download
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"os"
@@ -13,202 +12,284 @@ import (
 	"time"
 
 	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/backend"
+	"github.com/CDN18/femoji-cli/internal/filter"
+	"github.com/CDN18/femoji-cli/internal/progress"
 	"github.com/CDN18/femoji-cli/internal/util"
-	"github.com/owu-one/gotosocial-sdk/models"
 )
 
-type MisskeyResponse struct {
-	Emojis []MisskeyEmoji `json:"emojis"`
-}
-
-type MisskeyEmoji struct {
-	Aliases   []string `json:"aliases"`
-	Name      string   `json:"name"`
-	Category  *string  `json:"category"`
-	URL       string   `json:"url"`
-	LocalOnly bool     `json:"localOnly"`
-	Sensitive bool     `json:"isSensitive"`
-	RoleIds   []string `json:"roleIdsThatCanBeUsedThisEmojiAsReaction"`
-}
-
-var mastodonLike = []string{"mastodon", "gotosocial", "pleroma", "akkoma", "hometown"}
-var misskeyLike = []string{"misskey", "firefish", "iceshrimp", "sharkey", "catodon", "foundkey"}
-
-func downloadWorker(id int, jobs <-chan *models.Emoji, wg *sync.WaitGroup, instance string, override bool) {
+func downloadWorker(ctx context.Context, id int, jobs <-chan *backend.Emoji, wg *sync.WaitGroup, b backend.Backend, layout *Layout, f *filter.Filter, override bool, manifest *Manifest, reporter *progress.Reporter) {
 	defer wg.Done()
 
+	logger := slog.With("worker", id)
 	for emoji := range jobs {
-		if emoji.Category == "" {
-			emoji.Category = "uncategorized"
-		}
-
-		dir := fmt.Sprintf("%s/%s", instance, emoji.Category)
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			if err := os.MkdirAll(dir, 0o755); err != nil {
-				slog.Error("failed to create directory", "worker", id, "error", err, "shortcode", emoji.Shortcode, "path", dir)
-				continue
-			}
-		}
-
-		extension := filepath.Ext(emoji.URL)
-		filePath := fmt.Sprintf("%s/%s%s", dir, emoji.Shortcode, extension)
-		if _, err := os.Stat(filePath); err == nil && !override {
-			slog.Info("skipping download as it already exists", "worker", id, "shortcode", emoji.Shortcode, "path", filePath)
+		if ctx.Err() != nil {
+			// Drain the queue without starting anything new.
 			continue
 		}
+		reporter.Record(emoji.Shortcode, downloadEmoji(ctx, logger, b, emoji, layout, f, override, manifest))
+	}
+}
 
-		resp, err := http.Get(emoji.URL)
-		if err != nil {
-			slog.Error("failed to download emoji", "worker", id, "error", err, "shortcode", emoji.Shortcode, "url", emoji.URL)
-			continue
-		}
+// downloadEmoji fetches one emoji to where the layout puts it, unless the manifest shows
+// we already have an intact copy that hasn't changed upstream.
+// If f only wants animated or static emojis, ones that turn out otherwise are skipped.
+func downloadEmoji(ctx context.Context, logger *slog.Logger, b backend.Backend, emoji *backend.Emoji, layout *Layout, f *filter.Filter, override bool, manifest *Manifest) progress.Result {
+	if emoji.Category == "" {
+		emoji.Category = "uncategorized"
+	}
 
-		if resp.Header.Get("x-ratelimit-remaining") == "0" {
-			slog.Error("rate limit exceeded", "worker", id)
-			resetTime, err := time.Parse(time.RFC1123, resp.Header.Get("x-ratelimit-reset"))
-			if err != nil {
-				resetTime = time.Now().Add(300 * time.Second)
+	// The extension is only known for sure once we've seen the content, so look for a copy under any of them.
+	filePath := existingEmojiFile(layout, emoji, urlExtension(emoji.URL))
+	exists := filePath != ""
+
+	// If we have a verified copy, make the request conditional so unchanged files aren't sent again.
+	header := http.Header{}
+	if exists && !override {
+		entry := manifest.Get(filePath)
+		switch {
+		case entry == nil:
+			logger.Info("existing file isn't in the manifest, downloading again", "shortcode", emoji.Shortcode, "path", filePath)
+		case entry.URL != emoji.URL:
+			logger.Info("emoji URL has changed, downloading again", "shortcode", emoji.Shortcode, "path", filePath)
+		case !verifyFile(filePath, entry):
+			logger.Warn("existing file is incomplete or corrupt, downloading again", "shortcode", emoji.Shortcode, "path", filePath)
+		case !matchAnimated(logger, f, emoji.Shortcode, filePath):
+			return progress.Skipped
+		case entry.ETag == "" && entry.LastModified == "":
+			logger.Info("skipping download as it already exists", "shortcode", emoji.Shortcode, "path", filePath)
+			return progress.Skipped
+		default:
+			if entry.ETag != "" {
+				header.Set("If-None-Match", entry.ETag)
 			}
-			slog.Info("rate limit will reset at", "worker", id, "time", resetTime)
-			time.Sleep(time.Until(resetTime))
-		}
-
-		if resp.StatusCode >= 300 && resp.StatusCode < 400 {
-			redirectURL := resp.Header.Get("Location")
-			resp, err = http.Get(redirectURL)
-			if err != nil {
-				slog.Error("failed to follow redirect", "worker", id, "error", err, "url", redirectURL)
-				continue
+			if entry.LastModified != "" {
+				header.Set("If-Modified-Since", entry.LastModified)
 			}
 		}
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			slog.Error("failed to download emoji", "worker", id, "status", resp.StatusCode, "shortcode", emoji.Shortcode, "url", emoji.URL)
-			continue
+	resp, err := b.FetchEmoji(ctx, emoji, header)
+	if err != nil {
+		if ctx.Err() != nil {
+			return progress.Cancelled
 		}
+		logger.Error("failed to download emoji", "error", err, "shortcode", emoji.Shortcode, "url", emoji.URL)
+		return progress.Failed
+	}
+	defer resp.Body.Close()
 
-		file, err := os.Create(filePath)
-		if err != nil {
-			resp.Body.Close()
-			slog.Error("failed to create file", "worker", id, "error", err, "shortcode", emoji.Shortcode, "path", filePath)
-			continue
-		}
+	if resp.StatusCode == http.StatusNotModified {
+		logger.Info("skipping download as it hasn't changed", "shortcode", emoji.Shortcode, "path", filePath)
+		return progress.Skipped
+	}
 
-		_, err = io.Copy(file, resp.Body)
-		resp.Body.Close()
-		file.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("failed to download emoji", "status", resp.StatusCode, "shortcode", emoji.Shortcode, "url", emoji.URL)
+		return progress.Failed
+	}
 
-		if err != nil {
-			slog.Error("failed to write to file", "worker", id, "error", err, "shortcode", emoji.Shortcode, "path", filePath)
-			continue
+	partialPath := layout.EmojiPath(emoji.Category, emoji.Shortcode, "")
+	dir := filepath.Dir(partialPath)
+	tmp, err := writeTemp(dir, filepath.Base(partialPath), resp.Body, resp.ContentLength)
+	if err != nil {
+		if ctx.Err() != nil {
+			return progress.Cancelled
 		}
+		logger.Error("failed to write to file", "error", err, "shortcode", emoji.Shortcode, "dir", dir)
+		return progress.Failed
+	}
 
-		slog.Info("downloaded emoji", "worker", id, "shortcode", emoji.Shortcode)
+	if !matchAnimated(logger, f, emoji.Shortcode, tmp.path) {
+		tmp.discard()
+		return progress.Skipped
 	}
-}
 
-func Download(authClient *auth.Client, instance string, category string, override bool, instanceType string, threadCount int, saveIndex bool) error {
-	if instance != "DEFAULT" {
-		if instanceType == "mastodon" {
-			nodeinfo, err := util.GetNodeInfo(instance)
-			if err != nil {
-				return err
-			}
+	newPath := layout.EmojiPath(emoji.Category, emoji.Shortcode, detectExtension(logger, emoji, tmp.path, resp.Header.Get("Content-Type")))
+	if err := tmp.commit(newPath); err != nil {
+		logger.Error("failed to write to file", "error", err, "shortcode", emoji.Shortcode, "path", newPath)
+		return progress.Failed
+	}
 
-			isMastodon := false
-			isMisskey := false
+	// Replace a copy saved under the wrong extension.
+	if exists && filePath != newPath {
+		if err := os.Remove(filePath); err != nil {
+			logger.Warn("failed to remove old copy of emoji", "error", err, "shortcode", emoji.Shortcode, "path", filePath)
+		}
+		manifest.Delete(filePath)
+	}
 
-			for _, name := range mastodonLike {
-				if nodeinfo.Software.Name == name {
-					isMastodon = true
-					break
-				}
-			}
+	manifest.Set(newPath, &ManifestEntry{
+		Shortcode:    emoji.Shortcode,
+		URL:          emoji.URL,
+		Size:         tmp.size,
+		SHA256:       tmp.sha256,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		DownloadedAt: time.Now().UTC(),
+	})
+
+	if exists {
+		return progress.Overridden
+	}
+	return progress.Done
+}
 
-			for _, name := range misskeyLike {
-				if nodeinfo.Software.Name == name {
-					isMisskey = true
-					break
-				}
-			}
+// matchAnimated checks an emoji's image against the filter's animation constraint, logging why it's skipped if it doesn't match.
+func matchAnimated(logger *slog.Logger, f *filter.Filter, shortcode string, path string) bool {
+	if !f.ChecksAnimation() {
+		return true
+	}
+	animated, err := util.IsAnimated(path)
+	if err != nil {
+		logger.Warn("couldn't tell whether emoji is animated, treating it as static", "shortcode", shortcode, "error", err)
+	}
+	if f.MatchAnimated(animated) {
+		return true
+	}
+	if animated {
+		logger.Info("skipping animated emoji", "shortcode", shortcode)
+	} else {
+		logger.Info("skipping static emoji", "shortcode", shortcode)
+	}
+	return false
+}
 
-			if isMisskey {
-				instanceType = "misskey"
-			} else if !isMastodon {
-				return fmt.Errorf("unknown instance type: %s", nodeinfo.Software.Name)
-			}
-		} else if instanceType != "misskey" {
-			return fmt.Errorf("invalid instance type: %s", instanceType)
+// existingEmojiFile returns the path an emoji was previously saved at, or "" if there isn't one.
+func existingEmojiFile(layout *Layout, emoji *backend.Emoji, urlExt string) string {
+	candidates := []string{urlExt, ".png", ".gif", ".webp", ".avif", ".jpg", ".jpeg", ".svg", ".apng", ""}
+	for _, ext := range candidates {
+		filePath := layout.EmojiPath(emoji.Category, emoji.Shortcode, ext)
+		if info, err := os.Stat(filePath); err == nil && info.Mode().IsRegular() {
+			return filePath
 		}
 	}
+	return ""
+}
 
-	var emojis []*models.Emoji
-	if instance == "DEFAULT" {
-		emojiResp, err := authClient.Client.CustomEmojis.CustomEmojisGet(nil, authClient.Auth)
-		if err != nil {
-			return err
+// detectExtension decides what extension a downloaded emoji should have,
+// trusting its content over the Content-Type header, and both over the URL.
+func detectExtension(logger *slog.Logger, emoji *backend.Emoji, path string, contentType string) string {
+	rawURLExt := urlExtension(emoji.URL)
+	urlExt := canonicalExtension(rawURLExt)
+	typeExt := extensionFromContentType(contentType)
+	sniffedExt, format := util.SniffImageFile(path)
+
+	switch {
+	case sniffedExt != "":
+		if urlExt != "" && urlExt != sniffedExt {
+			logger.Warn("emoji URL extension doesn't match its content, saving it with the right one", "shortcode", emoji.Shortcode, "url", emoji.URL, "format", format)
 		}
-		emojis = emojiResp.GetPayload()
-	} else if instanceType == "mastodon" {
-		endpoint := fmt.Sprintf("https://%s/api/v1/custom_emojis", instance)
-		resp, err := http.Get(endpoint)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK {
-			slog.Error("failed to get custom emojis", "status", resp.StatusCode, "instance", instance)
-			return nil
-		}
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		if err := json.Unmarshal(body, &emojis); err != nil {
-			return err
+		if typeExt != "" && typeExt != sniffedExt {
+			logger.Warn("emoji Content-Type doesn't match its content", "shortcode", emoji.Shortcode, "content_type", contentType, "format", format)
 		}
+		return sniffedExt
+	case typeExt != "":
+		return typeExt
+	case urlExt != "":
+		return urlExt
+	}
+
+	logger.Warn("couldn't identify emoji image format", "shortcode", emoji.Shortcode, "url", emoji.URL, "content_type", contentType)
+	return rawURLExt
+}
+
+// Options controls what Download fetches and how.
+type Options struct {
+	// Filter picks which emojis to download. nil downloads all of them.
+	Filter *filter.Filter
+	// Override re-downloads files that already exist.
+	Override bool
+	// Software is the backend family name, or backend.Auto.
+	Software string
+	// Threads is the number of parallel downloads, or 0 for one per CPU core.
+	Threads int
+	// SaveIndex writes the emoji list to index.json.
+	SaveIndex bool
+	// Packs downloads whole emoji packs from servers that have them.
+	Packs bool
+	// Output is the directory downloads are saved under. Defaults to the working directory.
+	Output string
+	// Layout is a LayoutPresets name or path template for each emoji under Output. Defaults to DefaultLayout.
+	// Emoji packs keep their own structure, so they're only downloaded with the default layout.
+	Layout string
+}
+
+// Download saves an instance's emojis where opts.Layout says, by default under a directory named after it.
+// If ctx is cancelled, in-flight downloads are abandoned, what finished is recorded, and ctx's error is returned.
+func Download(ctx context.Context, authClient *auth.Client, instance string, opts Options) error {
+	f := opts.Filter
+	override := opts.Override
+	threadCount := opts.Threads
+
+	var b backend.Backend
+	instanceDir := instance
+	if instance == "DEFAULT" {
+		b = backend.ForClient(authClient)
 	} else {
-		endpoint := fmt.Sprintf("https://%s/api/emojis", instance)
-		resp, err := http.Get(endpoint)
+		var err error
+		instance, err = util.NormalizeInstance(instance)
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			slog.Error("failed to get misskey emojis", "status", resp.StatusCode, "instance", instance)
-			return nil
-		}
+		instanceDir = util.InstanceDir(instance)
 
-		body, err := io.ReadAll(resp.Body)
+		b, err = backend.ForInstance(ctx, instance, opts.Software)
 		if err != nil {
 			return err
 		}
+	}
 
-		var misskeyResp MisskeyResponse
-		if err := json.Unmarshal(body, &misskeyResp); err != nil {
-			return err
-		}
+	layout, err := NewLayout(opts.Output, opts.Layout, instanceDir)
+	if err != nil {
+		return err
+	}
+	baseDir := layout.Dir()
 
-		for _, me := range misskeyResp.Emojis {
-			category := "uncategorized"
-			if me.Category != nil {
-				category = *me.Category
-			}
+	removeTempFiles(baseDir)
+
+	manifest, err := loadManifest(baseDir)
+	if err != nil {
+		slog.Error("failed to read download manifest", "error", err)
+		return err
+	}
 
-			emojis = append(emojis, &models.Emoji{
-				Category:  category,
-				Shortcode: me.Name,
-				URL:       me.URL,
-			})
+	src, ok := b.(backend.PackSource)
+	if ok && opts.Packs && !layout.IsDefault() {
+		// Packs keep their own directory structure, which would ignore the layout.
+		slog.Warn("not downloading emoji packs, as they can't follow a custom layout; downloading emojis individually instead")
+		ok = false
+	}
+	if ok && opts.Packs {
+		packs, err := src.ListPacks(ctx)
+		if err == nil {
+			slog.Info("Emoji Pack List Retrieved", "count", len(packs))
+			failures := downloadPacks(ctx, src, packs, baseDir, f, override, manifest)
+			if ctx.Err() != nil {
+				return failures
+			}
+			// Save the index even if some files failed, as the emoji list does.
+			if opts.SaveIndex {
+				if err := saveEmojiIndex(ctx, b, baseDir, f); err != nil {
+					return err
+				}
+			}
+			return failures
 		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.Warn("failed to list emoji packs, falling back to the emoji list", "instance", instance, "error", err)
 	}
 
-	if category != "*" {
-		emojis, _ = util.FilterEmojisByCategory(emojis, category)
+	emojis, err := b.ListEmojis(ctx)
+	if err != nil {
+		slog.Error("failed to get custom emojis", "instance", instance, "backend", b.Name(), "error", err)
+		return err
 	}
 
+	emojis = backend.FilterEmojis(emojis, f)
+
 	totalCount := len(emojis)
 	slog.Info("Emoji List Retrieved", "count", totalCount)
 
@@ -216,15 +297,16 @@ func Download(authClient *auth.Client, instance string, category string, overrid
 		threadCount = runtime.NumCPU()
 	}
 
+	reporter := progress.New("downloaded", totalCount)
 	if threadCount > 1 {
 		slog.Info("Starting multi-threaded download", "threads", threadCount)
 
-		jobs := make(chan *models.Emoji, totalCount)
+		jobs := make(chan *backend.Emoji, totalCount)
 		var wg sync.WaitGroup
 
 		for i := 0; i < threadCount; i++ {
 			wg.Add(1)
-			go downloadWorker(i+1, jobs, &wg, instance, override)
+			go downloadWorker(ctx, i+1, jobs, &wg, b, layout, f, override, manifest, reporter)
 		}
 
 		for _, emoji := range emojis {
@@ -235,104 +317,71 @@ func Download(authClient *auth.Client, instance string, category string, overrid
 		wg.Wait()
 	} else {
 		for _, emoji := range emojis {
-			if emoji.Category == "" {
-				emoji.Category = "uncategorized"
-			}
-			slog.Info(fmt.Sprintf("downloading emoji %d/%d", 1, totalCount), "shortcode", emoji.Shortcode, "category", emoji.Category, "url", emoji.URL)
-			dir := fmt.Sprintf("%s/%s", instance, emoji.Category)
-			// create dir if not exists
-			if _, err := os.Stat(dir); os.IsNotExist(err) {
-				if err := os.MkdirAll(dir, 0o755); err != nil {
-					slog.Error("failed to create directory", "error", err, "shortcode", emoji.Shortcode, "path", dir)
-					continue
-				}
-			}
-			// download emoji
-			extension := filepath.Ext(emoji.URL)
-			filePath := fmt.Sprintf("%s/%s%s", dir, emoji.Shortcode, extension)
-			if _, err := os.Stat(filePath); err == nil && !override {
-				slog.Info("skipping download as it already exists", "shortcode", emoji.Shortcode, "path", filePath)
-				continue
-			}
-			resp, err := http.Get(emoji.URL)
-			if err != nil {
-				slog.Error("failed to download emoji", "error", err, "shortcode", emoji.Shortcode, "url", emoji.URL)
-				continue
-			}
-			// check x-ratelimit-remaining and x-ratelimit-reset headers
-			if resp.Header.Get("x-ratelimit-remaining") == "0" {
-				slog.Error("rate limit exceeded")
-				resetTime, err := time.Parse(time.RFC1123, resp.Header.Get("x-ratelimit-reset"))
-				if err != nil {
-					slog.Error("failed to parse x-ratelimit-reset header, using default of 300 seconds", "error", err)
-					// default to 300 seconds
-					resetTime = time.Now().Add(300 * time.Second)
-				}
-				slog.Info("rate limit will reset at", "time", resetTime)
-				time.Sleep(time.Until(resetTime))
-			}
-			// follow 3xx redirects
-			if resp.StatusCode >= 300 && resp.StatusCode < 400 {
-				redirectURL := resp.Header.Get("Location")
-				resp, err = http.Get(redirectURL)
-				if err != nil {
-					slog.Error("failed to follow redirect", "error", err, "url", redirectURL)
-					continue
-				}
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				slog.Error("failed to download emoji", "status", resp.StatusCode, "shortcode", emoji.Shortcode, "url", emoji.URL)
-				continue
-			}
-			file, err := os.Create(filePath)
-			if err != nil {
-				slog.Error("failed to create file", "error", err, "shortcode", emoji.Shortcode, "path", filePath)
-				continue
-			}
-			defer file.Close()
-			if _, err := io.Copy(file, resp.Body); err != nil {
-				slog.Error("failed to write to file", "error", err, "shortcode", emoji.Shortcode, "path", filePath)
-				continue
-			}
-			// check rate-limit, again
-			if resp.Header.Get("x-ratelimit-remaining") == "0" {
-				slog.Error("rate limit exceeded")
-				resetTime, err := time.Parse(time.RFC1123, resp.Header.Get("x-ratelimit-reset"))
-				if err != nil {
-					slog.Error("failed to parse x-ratelimit-reset header, using default of 300 seconds", "error", err)
-					// default to 300 seconds
-					resetTime = time.Now().Add(300 * time.Second)
-				}
-				slog.Info("rate limit will reset at", "time", resetTime)
-				time.Sleep(time.Until(resetTime))
+			if ctx.Err() != nil {
+				break
 			}
+			reporter.Record(emoji.Shortcode, downloadEmoji(ctx, slog.Default(), b, emoji, layout, f, override, manifest))
 		}
 	}
 
-	if saveIndex {
-		if _, err := os.Stat(instance); os.IsNotExist(err) {
-			if err := os.MkdirAll(instance, 0o755); err != nil {
-				slog.Error("failed to create instance directory", "error", err)
-				return err
-			}
-		}
-		f, err := os.Create(filepath.Join(instance, "index.json"))
-		if err != nil {
-			slog.Error("failed to create index.json", "error", err)
+	failures := reporter.Finish()
+
+	if err := manifest.Save(); err != nil {
+		slog.Error("failed to save download manifest", "error", err)
+		return err
+	}
+
+	if ctx.Err() != nil {
+		slog.Warn("Interrupted! Progress so far has been saved")
+		return ctx.Err()
+	}
+
+	if opts.SaveIndex {
+		if err := writeIndex(baseDir, emojis); err != nil {
 			return err
 		}
-		defer f.Close()
+	}
+
+	if failures != nil {
+		return failures
+	}
+
+	slog.Info("Completed!")
+	return nil
+}
+
+// saveEmojiIndex fetches the emoji list and writes it to index.json.
+func saveEmojiIndex(ctx context.Context, b backend.Backend, dir string, f *filter.Filter) error {
+	emojis, err := b.ListEmojis(ctx)
+	if err != nil {
+		slog.Error("failed to get custom emojis", "backend", b.Name(), "error", err)
+		return err
+	}
+	return writeIndex(dir, backend.FilterEmojis(emojis, f))
+}
 
-		encoder := json.NewEncoder(f)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(emojis); err != nil {
-			slog.Error("failed to write index.json", "error", err)
+// writeIndex saves the emoji list as index.json in dir.
+func writeIndex(dir string, emojis []*backend.Emoji) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			slog.Error("failed to create instance directory", "error", err)
 			return err
 		}
-		slog.Info("saved emoji index", "path", filepath.Join(instance, "index.json"))
 	}
+	f, err := os.Create(filepath.Join(dir, "index.json"))
+	if err != nil {
+		slog.Error("failed to create index.json", "error", err)
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(emojis); err != nil {
+		slog.Error("failed to write index.json", "error", err)
+		return err
+	}
+	slog.Info("saved emoji index", "path", filepath.Join(dir, "index.json"))
 
-	slog.Info(fmt.Sprintf("Completed! Downloaded %d emojis", totalCount))
 	return nil
 }
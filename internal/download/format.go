@@ -0,0 +1,49 @@
+package download
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// contentTypeExtensions maps image MIME types to the extension we save them with.
+// APNG is saved as .png: it's a valid PNG, and that's the name instances accept it under.
+var contentTypeExtensions = map[string]string{
+	"image/png":     ".png",
+	"image/apng":    ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/avif":    ".avif",
+	"image/jpeg":    ".jpg",
+	"image/svg+xml": ".svg",
+}
+
+// canonicalExtension returns the extension we'd use for a file with extension ext, or "" if it isn't an image.
+func canonicalExtension(ext string) string {
+	switch ext = strings.ToLower(ext); ext {
+	case ".png", ".apng":
+		return ".png"
+	case ".jpg", ".jpeg":
+		return ".jpg"
+	case ".gif", ".webp", ".avif", ".svg":
+		return ext
+	}
+	return ""
+}
+
+// extensionFromContentType returns the extension for a Content-Type header, or "" if it isn't an image type we know.
+func extensionFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return contentTypeExtensions[mediaType]
+}
+
+// urlExtension returns the extension of a URL's path, ignoring any query string.
+func urlExtension(rawURL string) string {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	return filepath.Ext(rawURL)
+}
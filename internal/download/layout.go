@@ -0,0 +1,137 @@
+package download
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultLayout is where downloads have always gone: a directory per instance, with a subdirectory per category.
+const DefaultLayout = "{instance}/{category}/{shortcode}{ext}"
+
+// LayoutPresets are named layouts that can be given instead of a template.
+var LayoutPresets = map[string]string{
+	"default": DefaultLayout,
+	"flat":    "{shortcode}{ext}",
+}
+
+var (
+	layoutPlaceholder  = regexp.MustCompile(`\{[^{}]*\}`)
+	layoutPlaceholders = []string{"{instance}", "{category}", "{shortcode}", "{ext}"}
+)
+
+// Layout decides where each downloaded emoji is saved, by filling in a path template under a root directory.
+// Values filled in from the server are sanitised so they can't escape the directory or upset the filesystem.
+type Layout struct {
+	root     string
+	instance string
+	template string
+	segments []string
+}
+
+// NewLayout parses a layout preset name or template. instance is the value of {instance}.
+func NewLayout(root string, template string, instance string) (*Layout, error) {
+	if template == "" {
+		template = DefaultLayout
+	}
+	if preset, ok := LayoutPresets[template]; ok {
+		template = preset
+	}
+
+	if filepath.IsAbs(template) || strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("layout must be a relative path: %q", template)
+	}
+	for _, placeholder := range layoutPlaceholder.FindAllString(template, -1) {
+		if !slices.Contains(layoutPlaceholders, placeholder) {
+			return nil, fmt.Errorf("unknown placeholder %s in layout (use %s)", placeholder, strings.Join(layoutPlaceholders, ", "))
+		}
+	}
+	if !strings.Contains(template, "{shortcode}") || !strings.Contains(template, "{ext}") {
+		return nil, fmt.Errorf("layout must contain {shortcode} and {ext}: %q", template)
+	}
+
+	segments := strings.Split(filepath.ToSlash(template), "/")
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("layout has an empty, . or .. path segment: %q", template)
+		}
+	}
+
+	if root == "" {
+		root = "."
+	}
+
+	return &Layout{
+		root:     root,
+		instance: instance,
+		template: template,
+		segments: segments,
+	}, nil
+}
+
+// IsDefault reports whether the layout is DefaultLayout, which emoji packs fit into.
+func (l *Layout) IsDefault() bool {
+	return l.template == DefaultLayout
+}
+
+// Dir returns the deepest directory that every emoji is saved under.
+// That's where the manifest, index.json and emoji packs go.
+func (l *Layout) Dir() string {
+	parts := []string{l.root}
+	for _, segment := range l.segments[:len(l.segments)-1] {
+		if strings.Contains(strings.ReplaceAll(segment, "{instance}", ""), "{") {
+			break
+		}
+		parts = append(parts, l.render(segment, "", "", ""))
+	}
+	return filepath.Join(parts...)
+}
+
+// EmojiPath returns where an emoji with the given category, shortcode and file extension is saved.
+func (l *Layout) EmojiPath(category string, shortcode string, ext string) string {
+	parts := []string{l.root}
+	for _, segment := range l.segments {
+		parts = append(parts, l.render(segment, category, shortcode, ext))
+	}
+	return filepath.Join(parts...)
+}
+
+func (l *Layout) render(segment string, category string, shortcode string, ext string) string {
+	return strings.NewReplacer(
+		"{instance}", sanitizePathComponent(l.instance),
+		"{category}", sanitizePathComponent(category),
+		"{shortcode}", sanitizePathComponent(shortcode),
+		"{ext}", ext,
+	).Replace(segment)
+}
+
+// windowsReservedNames can't be used as file names on Windows, with or without an extension.
+var windowsReservedNames = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[0-9]|lpt[0-9])(\..*)?$`)
+
+// sanitizePathComponent makes a server-provided name safe to use as a single path component
+// on common filesystems: no separators, no characters Windows forbids, no traversal, and not hidden.
+func sanitizePathComponent(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\<>:"|?*`, r) {
+			b.WriteRune('_')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	// Windows drops trailing dots and spaces, and a leading dot hides the file elsewhere.
+	sanitized := strings.TrimRight(b.String(), ". ")
+	if strings.HasPrefix(sanitized, ".") {
+		sanitized = "_" + sanitized[1:]
+	}
+	if sanitized == "" {
+		return "_"
+	}
+	if windowsReservedNames.MatchString(sanitized) {
+		sanitized = "_" + sanitized
+	}
+	return sanitized
+}
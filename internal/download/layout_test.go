@@ -0,0 +1,111 @@
+package download
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizePathComponent(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "blobcat", "blobcat"},
+		{"unicode", "ねこ", "ねこ"},
+		{"empty", "", "_"},
+		{"dot", ".", "_"},
+		{"dot dot", "..", "_"},
+		{"traversal", "../etc", "_._etc"},
+		{"slash", "a/b", "a_b"},
+		{"backslash", `a\b`, "a_b"},
+		{"windows traversal", `..\..\x`, "_._.._x"},
+		{"forbidden characters", `a<b>c:d"e|f?g*h`, "a_b_c_d_e_f_g_h"},
+		{"control characters", "a\x00b\x1fc\x7f", "a_b_c_"},
+		{"leading dot", ".hidden", "_hidden"},
+		{"trailing dot", "name.", "name"},
+		{"trailing dots and spaces", "name. . ", "name"},
+		{"reserved name", "con", "_con"},
+		{"reserved name upper case", "NUL", "_NUL"},
+		{"reserved name with extension", "aux.png", "_aux.png"},
+		{"reserved name with number", "lpt1", "_lpt1"},
+		{"reserved name prefix", "console", "console"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizePathComponent(tt.in); got != tt.want {
+				t.Errorf("sanitizePathComponent(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmojiPathStaysUnderRoot(t *testing.T) {
+	root := filepath.Join("out", "emoji")
+	layout, err := NewLayout(root, DefaultLayout, "example.social")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		category  string
+		shortcode string
+	}{
+		{"..", ".."},
+		{"../..", "x"},
+		{"a/../../..", "b"},
+		{`..\..`, `..\x`},
+		{"/etc", "/passwd"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		path := layout.EmojiPath(tt.category, tt.shortcode, ".png")
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			t.Errorf("EmojiPath(%q, %q) = %q, which is outside %q", tt.category, tt.shortcode, path, root)
+		}
+		if got := len(strings.Split(rel, string(filepath.Separator))); got != 3 {
+			t.Errorf("EmojiPath(%q, %q) = %q, want 3 path components under the root, got %d", tt.category, tt.shortcode, path, got)
+		}
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	base := filepath.Join("out", "pack")
+
+	tests := []struct {
+		name string
+		rel  string
+		want string
+		ok   bool
+	}{
+		{"file", "a.png", filepath.Join(base, "a.png"), true},
+		{"subdirectory", "sub/a.png", filepath.Join(base, "sub", "a.png"), true},
+		{"back out and in", "a/../b.png", filepath.Join(base, "b.png"), true},
+		{"empty", "", "", false},
+		{"dot", ".", "", false},
+		{"dot dot", "..", "", false},
+		{"parent", "../x", "", false},
+		{"escape through subdirectory", "a/../../x", "", false},
+		{"escape to base", "a/..", "", false},
+		{"deep escape", "a/b/../../../x", "", false},
+		{"absolute", "/etc/passwd", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := safeJoin(base, tt.rel)
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("safeJoin(%q, %q) = %q, want an error", base, tt.rel, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("safeJoin(%q, %q) returned error %v", base, tt.rel, err)
+			} else if got != tt.want {
+				t.Errorf("safeJoin(%q, %q) = %q, want %q", base, tt.rel, got, tt.want)
+			}
+		})
+	}
+}
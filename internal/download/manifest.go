@@ -0,0 +1,181 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// manifestName is the file in each instance directory that records what we've downloaded.
+const manifestName = ".femoji-manifest.json"
+
+const manifestVersion = 1
+
+// The manifest is saved after this many changes, or once this long has passed since the last save,
+// so a crash or kill mid-run only loses the last few entries.
+const (
+	manifestSaveEvery    = 20
+	manifestSaveInterval = 5 * time.Second
+)
+
+// Manifest records every downloaded emoji file so later runs can tell complete files
+// from truncated or corrupt ones, and ask the server whether they've changed.
+// It's safe for concurrent use.
+type Manifest struct {
+	mu   sync.Mutex
+	path string
+	// unsaved counts changes since lastSave.
+	unsaved  int
+	lastSave time.Time
+	Version  int                       `json:"version"`
+	Entries  map[string]*ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry describes one downloaded file. Entries are keyed by slash-separated path relative to the instance directory.
+type ManifestEntry struct {
+	Shortcode    string    `json:"shortcode"`
+	URL          string    `json:"url"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// loadManifest reads the manifest from an instance directory, or starts an empty one.
+func loadManifest(instanceDir string) (*Manifest, error) {
+	m := &Manifest{
+		path:     filepath.Join(instanceDir, manifestName),
+		lastSave: time.Now(),
+		Version:  manifestVersion,
+		Entries:  map[string]*ManifestEntry{},
+	}
+
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Entries == nil {
+		m.Entries = map[string]*ManifestEntry{}
+	}
+
+	return m, nil
+}
+
+// Get returns the entry for a file, or nil if there isn't one.
+func (m *Manifest) Get(filePath string) *ManifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.Entries[m.key(filePath)]
+}
+
+func (m *Manifest) Set(filePath string, entry *ManifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Entries[m.key(filePath)] = entry
+	m.changed()
+}
+
+func (m *Manifest) Delete(filePath string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.Entries, m.key(filePath))
+	m.changed()
+}
+
+// changed notes a change, saving the manifest if enough have built up. m.mu must be held.
+func (m *Manifest) changed() {
+	m.unsaved++
+	if m.unsaved < manifestSaveEvery && time.Since(m.lastSave) < manifestSaveInterval {
+		return
+	}
+	if err := m.save(); err != nil {
+		slog.Warn("failed to save download manifest", "error", err)
+	}
+}
+
+// key converts a file path to the path relative to the instance directory that entries are stored under.
+func (m *Manifest) key(filePath string) string {
+	rel, err := filepath.Rel(filepath.Dir(m.path), filePath)
+	if err != nil {
+		rel = filePath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Save writes the manifest to disk.
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.save()
+}
+
+func (m *Manifest) save() error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return err
+	}
+
+	m.unsaved = 0
+	m.lastSave = time.Now()
+	return nil
+}
+
+// verifyFile returns true if the file on disk matches the size and checksum recorded in the entry.
+func verifyFile(filePath string, entry *ManifestEntry) bool {
+	info, err := os.Stat(filePath)
+	if err != nil || info.Size() != entry.Size {
+		return false
+	}
+
+	sum, err := fileSHA256(filePath)
+	if err != nil {
+		return false
+	}
+
+	return sum == entry.SHA256
+}
+
+func fileSHA256(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,334 @@
+package download
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CDN18/femoji-cli/internal/backend"
+	"github.com/CDN18/femoji-cli/internal/filter"
+	"github.com/CDN18/femoji-cli/internal/progress"
+)
+
+// downloadPacks saves each emoji pack as a directory named after the pack, containing its pack.json and files.
+// Shareable packs are fetched as a single archive; others file by file.
+// Packs are filtered as categories. If f picks out individual emojis, the rest of each pack is left out.
+// Files are recorded in the manifest like other downloads, so intact copies aren't fetched again.
+func downloadPacks(ctx context.Context, src backend.PackSource, packs []*backend.Pack, instanceDir string, f *filter.Filter, override bool, manifest *Manifest) error {
+	var selected []*backend.Pack
+	total := 0
+	for _, pack := range packs {
+		if !f.MatchCategory(pack.Name) {
+			continue
+		}
+		if f.SelectsShortcodes() {
+			if pack = filterPack(pack, f); len(pack.Files) == 0 {
+				continue
+			}
+		}
+		selected = append(selected, pack)
+		total += len(pack.Files)
+	}
+
+	reporter := progress.New("downloaded", total)
+	for _, pack := range selected {
+		if ctx.Err() != nil {
+			break
+		}
+
+		dir, err := safeJoin(instanceDir, pack.Name)
+		if err != nil {
+			slog.Error("skipping pack with unsafe name", "pack", pack.Name, "error", err)
+			recordPack(reporter, pack, progress.Failed)
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			slog.Error("failed to create directory", "error", err, "pack", pack.Name, "path", dir)
+			recordPack(reporter, pack, progress.Failed)
+			continue
+		}
+
+		// The archive has the whole pack, so only use it if we want the whole pack.
+		if pack.Info.CanDownload && !f.SelectsShortcodes() {
+			if !override && packIntact(pack, dir, manifest) {
+				slog.Info("skipping pack as all its files already exist", "pack", pack.Name)
+				recordPack(reporter, pack, progress.Skipped)
+				continue
+			}
+			err = downloadPackArchive(ctx, src, pack, dir, override, manifest)
+			if err == nil {
+				slog.Info("downloaded pack archive", "pack", pack.Name, "files", len(pack.Files))
+				recordPack(reporter, pack, progress.Done)
+				continue
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			slog.Warn("failed to download pack archive, falling back to individual files", "pack", pack.Name, "error", err)
+		}
+
+		if err := writePackFile(pack, dir, override); err != nil {
+			slog.Error("failed to write pack.json", "error", err, "pack", pack.Name)
+		}
+
+		for shortcode, file := range pack.Files {
+			if ctx.Err() != nil {
+				break
+			}
+			reporter.Record(shortcode, downloadPackEmoji(ctx, src, pack, shortcode, file, dir, f, override, manifest))
+		}
+	}
+
+	failures := reporter.Finish()
+
+	if err := manifest.Save(); err != nil {
+		slog.Error("failed to save download manifest", "error", err)
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return failures
+}
+
+// filterPack returns a copy of pack with only the files whose shortcodes match f.
+func filterPack(pack *backend.Pack, f *filter.Filter) *backend.Pack {
+	filtered := *pack
+	filtered.Files = map[string]string{}
+	for shortcode, file := range pack.Files {
+		if f.Match(shortcode, pack.Name) {
+			filtered.Files[shortcode] = file
+		}
+	}
+	return &filtered
+}
+
+// recordPack records the same result for every emoji in a pack.
+func recordPack(reporter *progress.Reporter, pack *backend.Pack, result progress.Result) {
+	for shortcode := range pack.Files {
+		reporter.Record(shortcode, result)
+	}
+}
+
+// packIntact returns true if the manifest shows we have intact copies of every file in a pack.
+func packIntact(pack *backend.Pack, dir string, manifest *Manifest) bool {
+	for _, file := range pack.Files {
+		filePath, err := safeJoin(dir, file)
+		if err != nil {
+			return false
+		}
+		entry := manifest.Get(filePath)
+		if entry == nil || !verifyFile(filePath, entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// downloadPackEmoji fetches one file of a pack, unless the manifest shows we already have an intact copy.
+func downloadPackEmoji(ctx context.Context, src backend.PackSource, pack *backend.Pack, shortcode string, file string, dir string, f *filter.Filter, override bool, manifest *Manifest) progress.Result {
+	filePath, err := safeJoin(dir, file)
+	if err != nil {
+		slog.Error("skipping file with unsafe path", "pack", pack.Name, "shortcode", shortcode, "file", file, "error", err)
+		return progress.Failed
+	}
+
+	_, err = os.Stat(filePath)
+	exists := err == nil
+	if exists && !override {
+		entry := manifest.Get(filePath)
+		switch {
+		case entry == nil:
+			slog.Info("existing file isn't in the manifest, downloading again", "pack", pack.Name, "shortcode", shortcode, "path", filePath)
+		case !verifyFile(filePath, entry):
+			slog.Warn("existing file is incomplete or corrupt, downloading again", "pack", pack.Name, "shortcode", shortcode, "path", filePath)
+		default:
+			slog.Info("skipping download as it already exists", "pack", pack.Name, "shortcode", shortcode, "path", filePath)
+			return progress.Skipped
+		}
+	}
+
+	matched, err := downloadPackFile(ctx, src, pack, shortcode, file, filePath, f, manifest)
+	if err != nil {
+		if ctx.Err() != nil {
+			return progress.Cancelled
+		}
+		slog.Error("failed to download emoji", "error", err, "pack", pack.Name, "shortcode", shortcode, "file", file)
+		return progress.Failed
+	}
+	if !matched {
+		return progress.Skipped
+	}
+
+	if exists {
+		return progress.Overridden
+	}
+	return progress.Done
+}
+
+// downloadPackFile saves a pack file to filePath and records it in the manifest.
+// It returns false, saving nothing, if it doesn't match f's animation constraint.
+func downloadPackFile(ctx context.Context, src backend.PackSource, pack *backend.Pack, shortcode string, file string, filePath string, f *filter.Filter, manifest *Manifest) (bool, error) {
+	resp, err := src.FetchPackFile(ctx, pack, file)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	tmp, err := writeTemp(filepath.Dir(filePath), filepath.Base(filePath), resp.Body, resp.ContentLength)
+	if err != nil {
+		return false, err
+	}
+	if !matchAnimated(slog.Default(), f, shortcode, tmp.path) {
+		tmp.discard()
+		return false, nil
+	}
+	if err := tmp.commit(filePath); err != nil {
+		return false, err
+	}
+
+	manifest.Set(filePath, &ManifestEntry{
+		Shortcode:    shortcode,
+		URL:          resp.Request.URL.String(),
+		Size:         tmp.size,
+		SHA256:       tmp.sha256,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		DownloadedAt: time.Now().UTC(),
+	})
+	return true, nil
+}
+
+// downloadPackArchive fetches a pack's zip archive and extracts it into dir, recording each file in the manifest.
+// Files the manifest shows we already have intact copies of are left alone.
+func downloadPackArchive(ctx context.Context, src backend.PackSource, pack *backend.Pack, dir string, override bool, manifest *Manifest) error {
+	resp, err := src.FetchPackArchive(ctx, pack)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// zip needs random access, so buffer the archive on disk first.
+	tmp, err := os.CreateTemp("", "femoji-pack-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, resp.Body)
+	if err != nil {
+		return err
+	}
+
+	archive, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return err
+	}
+
+	shortcodes := map[string]string{}
+	for shortcode, file := range pack.Files {
+		shortcodes[filepath.ToSlash(filepath.Clean(file))] = shortcode
+	}
+	archiveURL := resp.Request.URL.String()
+
+	for _, entry := range archive.File {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		filePath, err := safeJoin(dir, entry.Name)
+		if err != nil {
+			slog.Error("skipping archive entry with unsafe path", "pack", pack.Name, "entry", entry.Name, "error", err)
+			continue
+		}
+		if _, err := os.Stat(filePath); err == nil && !override {
+			if existing := manifest.Get(filePath); existing != nil && verifyFile(filePath, existing) {
+				continue
+			}
+		}
+		size, sum, err := extractZipEntry(entry, filePath)
+		if err != nil {
+			return err
+		}
+		manifest.Set(filePath, &ManifestEntry{
+			Shortcode:    shortcodes[filepath.ToSlash(filepath.Clean(entry.Name))],
+			URL:          archiveURL,
+			Size:         size,
+			SHA256:       sum,
+			DownloadedAt: time.Now().UTC(),
+		})
+	}
+
+	return nil
+}
+
+// extractZipEntry writes an archive entry to filePath, returning its size and SHA-256.
+func extractZipEntry(entry *zip.File, filePath string) (int64, string, error) {
+	r, err := entry.Open()
+	if err != nil {
+		return 0, "", err
+	}
+	defer r.Close()
+
+	return writeAtomic(filePath, r, int64(entry.UncompressedSize64))
+}
+
+// writePackFile writes the pack's metadata in Pleroma's pack.json format.
+func writePackFile(pack *backend.Pack, dir string, override bool) error {
+	filePath := filepath.Join(dir, "pack.json")
+	if _, err := os.Stat(filePath); err == nil && !override {
+		return nil
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	metadata := pack.Metadata
+	if len(metadata) == 0 {
+		metadata = json.RawMessage("{}")
+	}
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(backend.PackFile{
+		Pack:  metadata,
+		Files: pack.Files,
+	})
+}
+
+// safeJoin joins a server-provided relative path onto base, refusing anything that would escape it.
+func safeJoin(base string, rel string) (string, error) {
+	rel = filepath.FromSlash(rel)
+	if rel == "" || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return "", fmt.Errorf("invalid path: %q", rel)
+	}
+	joined := filepath.Join(base, rel)
+	within, err := filepath.Rel(base, joined)
+	if err != nil || within == "." || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path escapes directory: %q", rel)
+	}
+	return joined, nil
+}
@@ -0,0 +1,181 @@
+// Package filter selects emojis by category, shortcode and animation, for both download and upload.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Animation restricts emojis to animated or static ones.
+type Animation int
+
+const (
+	AnyAnimation Animation = iota
+	AnimatedOnly
+	StaticOnly
+)
+
+// Spec is a filter as given on the command line.
+type Spec struct {
+	// Categories to include. Empty means all of them, unless Uncategorized is set.
+	Categories []string
+	// Uncategorized includes emojis without a category, alongside any Categories.
+	Uncategorized bool
+	// Shortcodes to include. Empty means all of them.
+	Shortcodes []string
+	// Exclude drops emojis whose shortcode or category matches, even if they'd otherwise be included.
+	Exclude   []string
+	Animation Animation
+}
+
+// Filter decides which emojis to work with. Every pattern is a case-insensitive glob such as blobcat*,
+// or a regular expression between slashes such as /^blob(cat|fox)_/.
+// The zero value, and nil, match everything.
+type Filter struct {
+	categories    []*pattern
+	uncategorized bool
+	shortcodes    []*pattern
+	exclude       []*pattern
+	animation     Animation
+}
+
+// New compiles a Spec, checking that its patterns are valid.
+func New(spec Spec) (*Filter, error) {
+	f := &Filter{
+		uncategorized: spec.Uncategorized,
+		animation:     spec.Animation,
+	}
+
+	var err error
+	if f.categories, err = compile(spec.Categories); err != nil {
+		return nil, err
+	}
+	if f.shortcodes, err = compile(spec.Shortcodes); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compile(spec.Exclude); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// IsUncategorized reports whether a category name means the emoji doesn't have one.
+func IsUncategorized(category string) bool {
+	return category == "" || category == "uncategorized"
+}
+
+// MatchCategory reports whether emojis in a category can match the filter, going by the category alone.
+func (f *Filter) MatchCategory(category string) bool {
+	if f == nil {
+		return true
+	}
+
+	for _, p := range f.exclude {
+		if p.match(category) {
+			return false
+		}
+	}
+
+	if len(f.categories) == 0 && !f.uncategorized {
+		return true
+	}
+	if f.uncategorized && IsUncategorized(category) {
+		return true
+	}
+	for _, p := range f.categories {
+		if p.match(category) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether an emoji's shortcode and category match the filter.
+// Whether it's animated has to be checked separately, with MatchAnimated, once its image is at hand.
+func (f *Filter) Match(shortcode string, category string) bool {
+	if f == nil {
+		return true
+	}
+
+	if !f.MatchCategory(category) {
+		return false
+	}
+	for _, p := range f.exclude {
+		if p.match(shortcode) {
+			return false
+		}
+	}
+
+	if len(f.shortcodes) == 0 {
+		return true
+	}
+	for _, p := range f.shortcodes {
+		if p.match(shortcode) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChecksAnimation reports whether MatchAnimated can reject anything.
+func (f *Filter) ChecksAnimation() bool {
+	return f != nil && f.animation != AnyAnimation
+}
+
+// MatchAnimated reports whether an emoji with or without animation matches the filter.
+func (f *Filter) MatchAnimated(animated bool) bool {
+	if f == nil {
+		return true
+	}
+
+	switch f.animation {
+	case AnimatedOnly:
+		return animated
+	case StaticOnly:
+		return !animated
+	}
+	return true
+}
+
+// SelectsShortcodes reports whether the filter picks individual emojis, rather than only whole categories.
+func (f *Filter) SelectsShortcodes() bool {
+	return f != nil && (len(f.shortcodes) > 0 || len(f.exclude) > 0 || f.animation != AnyAnimation)
+}
+
+// pattern is a compiled glob or regular expression.
+type pattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+func compile(exprs []string) ([]*pattern, error) {
+	var patterns []*pattern
+	for _, expr := range exprs {
+		if len(expr) >= 2 && strings.HasPrefix(expr, "/") && strings.HasSuffix(expr, "/") {
+			regex, err := regexp.Compile(expr[1 : len(expr)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %s: %w", expr, err)
+			}
+			patterns = append(patterns, &pattern{regex: regex})
+			continue
+		}
+
+		glob := strings.ToLower(expr)
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", expr, err)
+		}
+		patterns = append(patterns, &pattern{glob: glob})
+	}
+	return patterns, nil
+}
+
+func (p *pattern) match(s string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(s)
+	}
+	matched, _ := path.Match(p.glob, strings.ToLower(s))
+	return matched
+}
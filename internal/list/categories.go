@@ -0,0 +1,137 @@
+package list
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/backend"
+	"github.com/CDN18/femoji-cli/internal/filter"
+)
+
+// CategorySortKeys are the fields Categories can sort by.
+var CategorySortKeys = []string{"name", "count"}
+
+// Category is one emoji category as Categories prints it.
+type Category struct {
+	Name string `json:"name"`
+	// ID is only known for the logged-in instance.
+	ID    string `json:"id,omitempty"`
+	Count int    `json:"count"`
+}
+
+// CategoryOptions controls what Categories prints and how.
+type CategoryOptions struct {
+	// Software is the backend family name, or backend.Auto. Ignored for the logged-in instance.
+	Software string
+	// Format is one of Formats. Defaults to a table.
+	Format string
+	// Sort is one of CategorySortKeys. Ties are broken by name.
+	Sort    string
+	Reverse bool
+}
+
+// Categories prints an instance's emoji categories with how many emojis are in each.
+// Emojis without a category are counted as "uncategorized", as they're downloaded.
+// For authClient's instance ("DEFAULT"), the admin API adds category IDs and categories with no emojis.
+func Categories(ctx context.Context, authClient *auth.Client, instance string, opts CategoryOptions) error {
+	if opts.Format == "" {
+		opts.Format = "table"
+	}
+	if !slices.Contains(Formats, opts.Format) {
+		return fmt.Errorf("invalid format: %s (use %s)", opts.Format, strings.Join(Formats, ", "))
+	}
+	if opts.Sort == "" {
+		opts.Sort = "name"
+	}
+	if !slices.Contains(CategorySortKeys, opts.Sort) {
+		return fmt.Errorf("invalid sort key: %s (use %s)", opts.Sort, strings.Join(CategorySortKeys, ", "))
+	}
+
+	entries, err := listEntries(ctx, authClient, instance, opts.Software)
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]*Category{}
+	for _, entry := range entries {
+		name := entry.Category
+		if filter.IsUncategorized(name) {
+			name = "uncategorized"
+		}
+		if byName[name] == nil {
+			byName[name] = &Category{Name: name}
+		}
+		byName[name].Count++
+	}
+
+	if instance == "DEFAULT" {
+		adminCategories, err := backend.ForClient(authClient).ListCategories(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			slog.Warn("couldn't use the admin emoji API, so category IDs and empty categories are missing", "error", err)
+		}
+		for _, adminCategory := range adminCategories {
+			if byName[adminCategory.Name] == nil {
+				byName[adminCategory.Name] = &Category{Name: adminCategory.Name}
+			}
+			byName[adminCategory.Name].ID = adminCategory.ID
+		}
+	}
+
+	categories := make([]*Category, 0, len(byName))
+	for _, category := range byName {
+		categories = append(categories, category)
+	}
+	slices.SortFunc(categories, func(a, b *Category) int {
+		var c int
+		if opts.Sort == "count" {
+			c = cmp.Compare(a.Count, b.Count)
+		}
+		if c == 0 {
+			c = strings.Compare(a.Name, b.Name)
+		}
+		if opts.Reverse {
+			return -c
+		}
+		return c
+	})
+
+	switch opts.Format {
+	case "json":
+		return writeJSON(os.Stdout, categories)
+	case "csv":
+		return writeCategoriesCSV(os.Stdout, categories)
+	}
+	return writeCategoriesTable(os.Stdout, categories)
+}
+
+func writeCategoriesCSV(w io.Writer, categories []*Category) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"name", "id", "count"})
+	for _, category := range categories {
+		_ = cw.Write([]string{category.Name, category.ID, strconv.Itoa(category.Count)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeCategoriesTable(w io.Writer, categories []*Category) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CATEGORY\tID\tEMOJIS")
+	for _, category := range categories {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\n", category.Name, cmp.Or(category.ID, "-"), category.Count)
+	}
+	return tw.Flush()
+}
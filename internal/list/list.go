@@ -0,0 +1,212 @@
+// Package list prints the emojis and emoji categories on an instance without downloading anything.
+package list
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/backend"
+	"github.com/CDN18/femoji-cli/internal/filter"
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// Formats are the output formats List can print.
+var Formats = []string{"table", "json", "csv"}
+
+// SortKeys are the fields List can sort by.
+var SortKeys = []string{"shortcode", "category", "size"}
+
+// Entry is one emoji as List prints it.
+type Entry struct {
+	Shortcode       string `json:"shortcode"`
+	Category        string `json:"category"`
+	URL             string `json:"url"`
+	VisibleInPicker bool   `json:"visible_in_picker"`
+	Disabled        bool   `json:"disabled"`
+	// Size is the total size of the emoji's files in bytes, or 0 if the API doesn't say.
+	Size int64 `json:"size,omitempty"`
+}
+
+// Options controls what List prints and how.
+type Options struct {
+	// Software is the backend family name, or backend.Auto. Ignored for the logged-in instance.
+	Software string
+	// Filter picks which emojis to list. Animation constraints are ignored, since that needs the images.
+	Filter *filter.Filter
+	// Format is one of Formats. Defaults to a table.
+	Format string
+	// Sort is one of SortKeys. Ties are broken by shortcode.
+	Sort    string
+	Reverse bool
+}
+
+// List prints an instance's emojis to stdout. instance is "DEFAULT" for authClient's instance,
+// which is listed through the admin API if the user is allowed to, so disabled emojis and sizes are included.
+// authClient is only used for the "DEFAULT" instance.
+func List(ctx context.Context, authClient *auth.Client, instance string, opts Options) error {
+	if opts.Format == "" {
+		opts.Format = "table"
+	}
+	if !slices.Contains(Formats, opts.Format) {
+		return fmt.Errorf("invalid format: %s (use %s)", opts.Format, strings.Join(Formats, ", "))
+	}
+	if opts.Sort == "" {
+		opts.Sort = "shortcode"
+	}
+	if !slices.Contains(SortKeys, opts.Sort) {
+		return fmt.Errorf("invalid sort key: %s (use %s)", opts.Sort, strings.Join(SortKeys, ", "))
+	}
+
+	entries, err := listEntries(ctx, authClient, instance, opts.Software)
+	if err != nil {
+		return err
+	}
+
+	entries = slices.DeleteFunc(entries, func(entry *Entry) bool {
+		return !opts.Filter.Match(entry.Shortcode, entry.Category)
+	})
+	sortEntries(entries, opts.Sort, opts.Reverse)
+
+	switch opts.Format {
+	case "json":
+		return writeJSON(os.Stdout, entries)
+	case "csv":
+		return writeCSV(os.Stdout, entries)
+	}
+	return writeTable(os.Stdout, entries)
+}
+
+func listEntries(ctx context.Context, authClient *auth.Client, instance string, software string) ([]*Entry, error) {
+	if instance == "DEFAULT" {
+		b := backend.ForClient(authClient)
+		emojis, err := b.ListLocalEmojis(ctx)
+		if err == nil {
+			entries := make([]*Entry, 0, len(emojis))
+			for _, emoji := range emojis {
+				entries = append(entries, &Entry{
+					Shortcode:       emoji.Shortcode,
+					Category:        emoji.Category,
+					URL:             emoji.URL,
+					VisibleInPicker: emoji.VisibleInPicker,
+					Disabled:        emoji.Disabled,
+					Size:            emoji.TotalFileSize,
+				})
+			}
+			return entries, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		slog.Warn("couldn't use the admin emoji API, listing public emojis instead", "error", err)
+		return entriesFromBackend(ctx, b)
+	}
+
+	instance, err := util.NormalizeInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+	b, err := backend.ForInstance(ctx, instance, software)
+	if err != nil {
+		return nil, err
+	}
+	return entriesFromBackend(ctx, b)
+}
+
+func entriesFromBackend(ctx context.Context, b backend.Backend) ([]*Entry, error) {
+	emojis, err := b.ListEmojis(ctx)
+	if err != nil {
+		slog.Error("failed to get custom emojis", "backend", b.Name(), "error", err)
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(emojis))
+	for _, emoji := range emojis {
+		entries = append(entries, &Entry{
+			Shortcode:       emoji.Shortcode,
+			Category:        emoji.Category,
+			URL:             emoji.URL,
+			VisibleInPicker: emoji.VisibleInPicker,
+		})
+	}
+	return entries, nil
+}
+
+func sortEntries(entries []*Entry, key string, reverse bool) {
+	slices.SortStableFunc(entries, func(a, b *Entry) int {
+		var c int
+		switch key {
+		case "category":
+			c = strings.Compare(a.Category, b.Category)
+		case "size":
+			c = cmp.Compare(a.Size, b.Size)
+		}
+		if c == 0 {
+			c = strings.Compare(a.Shortcode, b.Shortcode)
+		}
+		if reverse {
+			return -c
+		}
+		return c
+	})
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func writeCSV(w io.Writer, entries []*Entry) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"shortcode", "category", "url", "visible_in_picker", "disabled", "size"})
+	for _, entry := range entries {
+		size := ""
+		if entry.Size > 0 {
+			size = strconv.FormatInt(entry.Size, 10)
+		}
+		_ = cw.Write([]string{
+			entry.Shortcode,
+			entry.Category,
+			entry.URL,
+			strconv.FormatBool(entry.VisibleInPicker),
+			strconv.FormatBool(entry.Disabled),
+			size,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeTable(w io.Writer, entries []*Entry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SHORTCODE\tCATEGORY\tVISIBLE\tDISABLED\tSIZE\tURL")
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Shortcode,
+			cmp.Or(entry.Category, "-"),
+			yesNo(entry.VisibleInPicker),
+			yesNo(entry.Disabled),
+			util.FormatSize(entry.Size),
+			entry.URL,
+		)
+	}
+	return tw.Flush()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
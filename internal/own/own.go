@@ -1,20 +1,21 @@
 package own
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+
 	"github.com/pkg/errors"
 
 	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/owu-one/gotosocial-sdk/client/accounts"
+	"github.com/owu-one/gotosocial-sdk/client/instance"
 	"github.com/owu-one/gotosocial-sdk/models"
 )
 
 // Account returns the currently authenticated account.
-func Account(authClient *auth.Client) (*models.Account, error) {
-	err := authClient.Wait()
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := authClient.Client.Accounts.AccountVerify(nil, authClient.Auth)
+func Account(ctx context.Context, authClient *auth.Client) (*models.Account, error) {
+	resp, err := authClient.Client.Accounts.AccountVerify(accounts.NewAccountVerifyParamsWithContext(ctx), authClient.Auth)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -22,14 +23,21 @@ func Account(authClient *auth.Client) (*models.Account, error) {
 	return resp.GetPayload(), nil
 }
 
-// Instance returns the instance of the currently authenticated account.
-func Instance(authClient *auth.Client) (*models.InstanceV2, error) {
-	err := authClient.Wait()
+// Verify checks that the client's access token is valid.
+func Verify(ctx context.Context, authClient *auth.Client) error {
+	account, err := Account(ctx, authClient)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	resp, err := authClient.Client.Instance.InstanceGetV2(nil)
+	slog.Info("access token verified", "account", account.Acct, "instance", authClient.Instance)
+
+	return nil
+}
+
+// Instance returns the instance of the currently authenticated account.
+func Instance(ctx context.Context, authClient *auth.Client) (*models.InstanceV2, error) {
+	resp, err := authClient.Client.Instance.InstanceGetV2(instance.NewInstanceGetV2ParamsWithContext(ctx))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -37,8 +45,8 @@ func Instance(authClient *auth.Client) (*models.InstanceV2, error) {
 	return resp.GetPayload(), nil
 }
 
-func Domain(authClient *auth.Client) (string, error) {
-	ownInstance, err := Instance(authClient)
+func Domain(ctx context.Context, authClient *auth.Client) (string, error) {
+	ownInstance, err := Instance(ctx, authClient)
 	if err != nil {
 		return "", err
 	}
@@ -53,3 +61,35 @@ func Domain(authClient *auth.Client) (string, error) {
 
 	return ownDomain, nil
 }
+
+// Whoami verifies the client's access token and prints who it belongs to.
+func Whoami(ctx context.Context, authClient *auth.Client) error {
+	account, err := Account(ctx, authClient)
+	if err != nil {
+		slog.Error("couldn't verify access token (it may have expired or been revoked; try logging in again)", "user", authClient.User, "instance", authClient.Instance, "error", err)
+		return err
+	}
+
+	user := authClient.User
+	if user == "" {
+		user = account.Acct + "@" + authClient.Instance
+	}
+
+	role := "user"
+	if account.Role != nil && account.Role.Name != "" {
+		role = account.Role.Name
+	}
+
+	fmt.Printf("%s\n", user)
+	fmt.Printf("  instance: %s\n", authClient.Instance)
+	fmt.Printf("  account:  %s (%s)\n", account.Acct, account.DisplayName)
+	fmt.Printf("  role:     %s\n", role)
+	fmt.Printf("  admin:    %t\n", IsAdmin(account))
+
+	return nil
+}
+
+// IsAdmin returns true if the account has an admin role.
+func IsAdmin(account *models.Account) bool {
+	return account.Role != nil && account.Role.Name == "admin"
+}
@@ -0,0 +1,183 @@
+// Package progress reports how far a batch of emoji transfers has got,
+// as a progress bar on a terminal or as log lines otherwise, and sums up the results at the end.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// Result is what happened to a single emoji.
+type Result int
+
+const (
+	// Done means the emoji was transferred.
+	Done Result = iota
+	// Skipped means there was nothing to do, e.g. because we already have it.
+	Skipped
+	// Overridden means the emoji was transferred in place of an existing one.
+	Overridden
+	// Renamed means the emoji was transferred under a different name, because its own was taken.
+	Renamed
+	Failed
+	// Cancelled means the run was interrupted before the emoji was finished. It isn't counted.
+	Cancelled
+)
+
+const barWidth = 30
+
+// Reporter tracks results as they come in. It's safe for concurrent use.
+type Reporter struct {
+	mu     sync.Mutex
+	verb   string
+	total  int
+	counts map[Result]int
+	failed []string
+	// last is the most recently finished emoji, shown next to the bar.
+	last string
+
+	out io.Writer
+	tty bool
+}
+
+// New starts reporting on total emojis. verb is the past tense of what's being done to them, e.g. "downloaded".
+// On a terminal, log output is redirected through the reporter until Finish so it doesn't garble the bar.
+func New(verb string, total int) *Reporter {
+	r := &Reporter{
+		verb:   verb,
+		total:  total,
+		counts: map[Result]int{},
+		out:    os.Stderr,
+		tty:    term.IsTerminal(int(os.Stderr.Fd())),
+	}
+	if r.tty {
+		log.SetOutput(r)
+		r.draw()
+	}
+	return r
+}
+
+// Record notes the result for one emoji.
+func (r *Reporter) Record(name string, result Result) {
+	if result == Cancelled {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counts[result]++
+	r.last = name
+	if result == Failed {
+		r.failed = append(r.failed, name)
+	}
+
+	if r.tty {
+		r.draw()
+	} else {
+		slog.Info(fmt.Sprintf("%s %d/%d", result.describe(r.verb), r.finished(), r.total), "shortcode", name)
+	}
+}
+
+// Count returns how many emojis had a result.
+func (r *Reporter) Count(result Result) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[result]
+}
+
+// Finish stops the progress bar and prints a summary, including which emojis failed.
+// It returns an error if any did.
+func (r *Reporter) Finish() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tty {
+		r.clear()
+		log.SetOutput(os.Stderr)
+	}
+
+	attrs := []any{
+		r.verb, r.counts[Done],
+		"overridden", r.counts[Overridden],
+		"skipped", r.counts[Skipped],
+		"failed", r.counts[Failed],
+	}
+	if renamed := r.counts[Renamed]; renamed > 0 {
+		attrs = append(attrs, "renamed", renamed)
+	}
+	if remaining := r.total - r.finished(); remaining > 0 {
+		attrs = append(attrs, "remaining", remaining)
+	}
+	slog.Info("Summary", attrs...)
+
+	if len(r.failed) == 0 {
+		return nil
+	}
+
+	sort.Strings(r.failed)
+	slog.Error("Some emojis failed", "shortcodes", strings.Join(r.failed, ", "))
+	return fmt.Errorf("%d of %d emojis failed", len(r.failed), r.total)
+}
+
+// Write lets log output through without mixing it into the progress bar.
+func (r *Reporter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clear()
+	n, err := r.out.Write(p)
+	r.draw()
+	return n, err
+}
+
+func (r *Reporter) finished() int {
+	return r.counts[Done] + r.counts[Skipped] + r.counts[Overridden] + r.counts[Renamed] + r.counts[Failed]
+}
+
+func (r *Reporter) clear() {
+	fmt.Fprint(r.out, "\r\033[K")
+}
+
+func (r *Reporter) draw() {
+	finished := r.finished()
+	filled := barWidth
+	if r.total > 0 {
+		filled = barWidth * finished / r.total
+	}
+	line := fmt.Sprintf("[%s%s] %d/%d", strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), finished, r.total)
+	if failed := r.counts[Failed]; failed > 0 {
+		line += fmt.Sprintf(" (%d failed)", failed)
+	}
+	if r.last != "" {
+		line += " " + r.last
+	}
+
+	// Don't let the line wrap, or clearing it will leave junk behind.
+	if width, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil && width > 1 && len(line) >= width {
+		line = line[:width-1]
+	}
+	fmt.Fprint(r.out, "\r\033[K"+line)
+}
+
+func (result Result) describe(verb string) string {
+	switch result {
+	case Skipped:
+		return "skipped"
+	case Overridden:
+		return "overrode"
+	case Renamed:
+		return "renamed"
+	case Failed:
+		return "failed"
+	}
+	return verb
+}
@@ -0,0 +1,54 @@
+package upload
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// OnConflict says what to do with an image whose shortcode is already taken on the instance.
+type OnConflict string
+
+const (
+	// Skip leaves the existing emoji alone and doesn't upload the image.
+	Skip OnConflict = "skip"
+	// Replace swaps the existing emoji's image for the new one, and moves it to the new category if one was given.
+	Replace OnConflict = "replace"
+	// Rename uploads the image under a new shortcode, made by adding a prefix and/or suffix.
+	Rename OnConflict = "rename"
+	// Fail refuses to upload anything if any shortcode is taken.
+	Fail OnConflict = "fail"
+)
+
+// ConflictStrategies lists every OnConflict value.
+var ConflictStrategies = []string{string(Skip), string(Replace), string(Rename), string(Fail)}
+
+// maxShortcodeLength is the longest shortcode GoToSocial accepts.
+const maxShortcodeLength = 30
+
+// affixPattern is what can go in a rename prefix or suffix: the characters allowed in shortcodes.
+var affixPattern = regexp.MustCompile(`^\w*$`)
+
+func (c OnConflict) validate(prefix string, suffix string) error {
+	if !slices.Contains(ConflictStrategies, string(c)) {
+		return fmt.Errorf("invalid conflict strategy: %s (use %s)", c, strings.Join(ConflictStrategies, ", "))
+	}
+	if !affixPattern.MatchString(prefix) || !affixPattern.MatchString(suffix) {
+		return fmt.Errorf("rename prefix and suffix can only contain letters, digits and underscores")
+	}
+	return nil
+}
+
+// renamedShortcode returns prefix+shortcode+suffix, numbered if need be so it isn't taken.
+func renamedShortcode(shortcode string, prefix string, suffix string, taken func(string) bool) (string, error) {
+	base := prefix + shortcode + suffix
+	renamed := base
+	for n := 2; renamed == shortcode || taken(renamed); n++ {
+		renamed = fmt.Sprintf("%s_%d", base, n)
+	}
+	if len(renamed) > maxShortcodeLength {
+		return "", fmt.Errorf("renamed shortcode %s is longer than %d characters", renamed, maxShortcodeLength)
+	}
+	return renamed, nil
+}
@@ -0,0 +1,192 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/CDN18/femoji-cli/internal/backend"
+)
+
+// indexEntry is what an index says about one emoji.
+type indexEntry struct {
+	shortcode string
+	// file is the image's path relative to the index, if the index says.
+	file            string
+	category        string
+	visibleInPicker bool
+	aliases         []string
+}
+
+// index holds per-emoji metadata to upload with, read from a file that came with the images.
+type index struct {
+	dir string
+	// byFile and byShortcode look up entries by file path relative to dir, and by shortcode.
+	// Images are matched by path if the index has them, as file names needn't match shortcodes.
+	byFile      map[string]*indexEntry
+	byShortcode map[string]*indexEntry
+}
+
+// misskeyExportEmoji is an entry in the meta.json of a Misskey emoji export.
+// The public emoji API lists the emojis themselves instead.
+type misskeyExportEmoji struct {
+	FileName string                `json:"fileName"`
+	Emoji    *backend.MisskeyEmoji `json:"emoji"`
+}
+
+// loadIndex reads an index in any of the formats we know:
+// our own index.json from download --save-index, a Misskey emoji list or export meta.json,
+// or a Pleroma pack.json, whose emojis are put in a category named after the pack's directory.
+func loadIndex(path string) (*index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parseIndex(data, filepath.Base(filepath.Dir(path)))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read index %s: %w", path, err)
+	}
+
+	idx := &index{
+		dir:         filepath.Dir(path),
+		byFile:      map[string]*indexEntry{},
+		byShortcode: map[string]*indexEntry{},
+	}
+	for _, entry := range entries {
+		if entry.shortcode == "" {
+			continue
+		}
+		if entry.file != "" {
+			idx.byFile[filepath.Clean(filepath.FromSlash(entry.file))] = entry
+		}
+		idx.byShortcode[entry.shortcode] = entry
+	}
+	return idx, nil
+}
+
+func parseIndex(data []byte, dirName string) ([]*indexEntry, error) {
+	data = bytes.TrimSpace(data)
+
+	// Ours is a plain list of emojis.
+	if bytes.HasPrefix(data, []byte("[")) {
+		var emojis []*backend.Emoji
+		if err := json.Unmarshal(data, &emojis); err != nil {
+			return nil, err
+		}
+		entries := make([]*indexEntry, 0, len(emojis))
+		for _, emoji := range emojis {
+			entries = append(entries, &indexEntry{
+				shortcode:       emoji.Shortcode,
+				category:        emoji.Category,
+				visibleInPicker: emoji.VisibleInPicker,
+				aliases:         emoji.Aliases,
+			})
+		}
+		return entries, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case object["files"] != nil:
+		var pack backend.PackFile
+		if err := json.Unmarshal(data, &pack); err != nil {
+			return nil, err
+		}
+		entries := make([]*indexEntry, 0, len(pack.Files))
+		for shortcode, file := range pack.Files {
+			entries = append(entries, &indexEntry{
+				shortcode:       shortcode,
+				file:            file,
+				category:        dirName,
+				visibleInPicker: true,
+			})
+		}
+		return entries, nil
+
+	case object["emojis"] != nil:
+		var items []json.RawMessage
+		if err := json.Unmarshal(object["emojis"], &items); err != nil {
+			return nil, err
+		}
+		entries := make([]*indexEntry, 0, len(items))
+		for _, item := range items {
+			var exported misskeyExportEmoji
+			if err := json.Unmarshal(item, &exported); err != nil {
+				return nil, err
+			}
+			emoji := exported.Emoji
+			if emoji == nil {
+				emoji = &backend.MisskeyEmoji{}
+				if err := json.Unmarshal(item, emoji); err != nil {
+					return nil, err
+				}
+			}
+			entry := &indexEntry{
+				shortcode:       emoji.Name,
+				file:            exported.FileName,
+				visibleInPicker: true,
+				aliases:         emoji.Aliases,
+			}
+			if emoji.Category != nil {
+				entry.category = *emoji.Category
+			}
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	}
+
+	return nil, fmt.Errorf("unknown index format (expected femoji's index.json, a Misskey meta.json or a Pleroma pack.json)")
+}
+
+// lookup returns the entry for the image at path, whose file name gives shortcode, or nil if the index doesn't have it.
+func (idx *index) lookup(path string, shortcode string) *indexEntry {
+	if rel, err := filepath.Rel(idx.dir, path); err == nil {
+		if entry := idx.byFile[rel]; entry != nil {
+			return entry
+		}
+	}
+	if entry := idx.byShortcode[shortcode]; entry != nil && entry.file == "" {
+		return entry
+	}
+	return nil
+}
+
+// reportIndex warns about index entries without images, and metadata GoToSocial can't store.
+func (c *collector) reportIndex() {
+	if c.index == nil {
+		return
+	}
+
+	var missing []string
+	withAliases, hidden := 0, 0
+	for shortcode, entry := range c.index.byShortcode {
+		if !c.matched[entry] {
+			missing = append(missing, shortcode)
+			continue
+		}
+		if len(entry.aliases) > 0 {
+			withAliases++
+		}
+		if !entry.visibleInPicker {
+			hidden++
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		slog.Warn("Couldn't find images for some emojis in the index", "count", len(missing), "shortcodes", strings.Join(missing, ", "))
+	}
+	if withAliases > 0 || hidden > 0 {
+		slog.Warn("GoToSocial can't set emoji aliases or hide emojis from the picker, so they'll be uploaded without", "with_aliases", withAliases, "hidden", hidden)
+	}
+}
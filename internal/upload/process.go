@@ -0,0 +1,242 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// previewSuffix is added to an animated GIF's shortcode for its static preview.
+const previewSuffix = "_static"
+
+// ProcessOptions says how to prepare images before they're checked and uploaded.
+// Processed images are written to a temporary directory; the originals are never changed.
+type ProcessOptions struct {
+	// Convert turns static images in formats the instance doesn't accept, such as JPEG, BMP or TIFF, into PNG.
+	Convert bool
+	// Resize downscales static images so neither side is longer than this many pixels. 0 leaves sizes alone.
+	Resize int
+	// Recompress re-encodes PNGs that are over the instance's size limit with the best lossless compression.
+	Recompress bool
+	// GIFPreviews adds a static emoji made from the first frame of each animated GIF, named with previewSuffix.
+	GIFPreviews bool
+}
+
+func (p *ProcessOptions) enabled() bool {
+	return p != nil && (p.Convert || p.Resize > 0 || p.Recompress || p.GIFPreviews)
+}
+
+// convertibleExtensions are the formats Convert accepts files in, besides the ones the instance takes as they are.
+var convertibleExtensions = []string{".jpg", ".jpeg", ".bmp", ".tif", ".tiff"}
+
+// unconvertibleFormats are formats people may want converted, but that can't be decoded without cgo, by extension.
+var unconvertibleFormats = map[string]string{
+	".avif": "AVIF",
+	".svg":  "SVG",
+}
+
+// converts reports whether Convert is set and can turn a file with the given name into PNG.
+func (p *ProcessOptions) converts(name string) bool {
+	return p != nil && p.Convert && slices.Contains(convertibleExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// processor prepares images for upload.
+type processor struct {
+	opts *ProcessOptions
+	// maxSize is the instance's emoji size limit, or 0 if it's unknown.
+	maxSize int64
+	// dir is where processed images are written.
+	dir string
+	// taken holds the shortcodes in the upload, so previews don't clash with them.
+	taken map[string]bool
+}
+
+// process prepares an image, changing its path if it was rewritten.
+// It returns any extra images made from it, i.e. a GIF's static preview.
+func (p *processor) process(img *localImage) ([]*localImage, error) {
+	ext, format := util.SniffImageFile(img.path)
+	animated, err := util.IsAnimated(img.path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(img.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var extra []*localImage
+	if p.opts.GIFPreviews && ext == ".gif" && animated {
+		preview, err := p.preview(img)
+		if err != nil {
+			slog.Warn("Couldn't make a static preview", "file", img.source, "error", err)
+		} else if preview != nil {
+			extra = append(extra, preview)
+		}
+	}
+
+	convert := p.opts.Convert && !acceptedFormats[ext]
+	if _, ok := unconvertibleFormats[ext]; ok && convert {
+		return nil, fmt.Errorf("can't convert %s images, as there's no decoder for them", format)
+	}
+	recompress := p.opts.Recompress && ext == ".png" && p.maxSize > 0 && info.Size() > p.maxSize
+	if !convert && !recompress && p.opts.Resize <= 0 {
+		return extra, nil
+	}
+	if animated {
+		// Re-encoding would lose all but the first frame.
+		width, height, _ := dimensions(img.path)
+		if convert || (p.opts.Resize > 0 && (width > p.opts.Resize || height > p.opts.Resize)) {
+			slog.Warn("Leaving animated image as it is, as only static images can be converted or resized", "file", img.source, "format", format)
+		}
+		return extra, nil
+	}
+
+	src, err := decode(img.path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode %s image: %w", format, err)
+	}
+
+	var changes []string
+	out := src
+	if resized := downscale(src, p.opts.Resize); resized != src {
+		changes = append(changes, fmt.Sprintf("resized %dx%d to %dx%d", src.Bounds().Dx(), src.Bounds().Dy(), resized.Bounds().Dx(), resized.Bounds().Dy()))
+		out = resized
+	}
+	if len(changes) == 0 && !convert && !recompress {
+		return extra, nil
+	}
+
+	data, err := encodePNG(out)
+	if err != nil {
+		return nil, err
+	}
+	if convert {
+		changes = append(changes, "converted "+format+" to PNG")
+	}
+	if len(changes) == 0 {
+		// Only recompressing: keep the original if we couldn't do any better.
+		if int64(len(data)) >= info.Size() {
+			slog.Info("Couldn't make image any smaller without losing quality", "file", img.source, "size", util.FormatSize(info.Size()))
+			return extra, nil
+		}
+		changes = append(changes, fmt.Sprintf("recompressed %s to %s", util.FormatSize(info.Size()), util.FormatSize(int64(len(data)))))
+	}
+
+	if img.path, err = p.write(data); err != nil {
+		return nil, err
+	}
+	slog.Info("Processed image", "file", img.source, "changes", strings.Join(changes, ", "))
+	return extra, nil
+}
+
+// preview makes a static emoji from the first frame of an animated GIF, or returns nil if its shortcode is taken.
+func (p *processor) preview(img *localImage) (*localImage, error) {
+	shortcode := img.shortcode + previewSuffix
+	if p.taken[shortcode] {
+		slog.Info("Not making a static preview, as its shortcode is taken", "file", img.source, "shortcode", shortcode)
+		return nil, nil
+	}
+
+	f, err := os.Open(img.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		return nil, err
+	}
+	// Frames can be smaller than the image, so draw the first onto a canvas of the full size.
+	frame := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	draw.Draw(frame, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+
+	data, err := encodePNG(downscale(frame, p.opts.Resize))
+	if err != nil {
+		return nil, err
+	}
+	path, err := p.write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p.taken[shortcode] = true
+	slog.Info("Made static preview", "file", img.source, "shortcode", shortcode)
+	return &localImage{
+		path:      path,
+		source:    img.source,
+		shortcode: shortcode,
+		category:  img.category,
+	}, nil
+}
+
+// write saves a processed image in p.dir under a name of its own,
+// as shortcodes from an index haven't been validated yet and mightn't be safe to use as file names.
+func (p *processor) write(data []byte) (string, error) {
+	f, err := os.CreateTemp(p.dir, "*.png")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func decode(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// downscale shrinks an image so neither side is longer than maxDimension, keeping its aspect ratio.
+// It returns the image unchanged if it's small enough already, or if maxDimension is 0.
+func downscale(img image.Image, maxDimension int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxDimension <= 0 || (width <= maxDimension && height <= maxDimension) {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxDimension/width)
+		width = maxDimension
+	} else {
+		width = max(1, width*maxDimension/height)
+		height = maxDimension
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -1,112 +1,405 @@
 package upload
 
 import (
+	"cmp"
+	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/backend"
+	"github.com/CDN18/femoji-cli/internal/filter"
+	"github.com/CDN18/femoji-cli/internal/progress"
 	"github.com/CDN18/femoji-cli/internal/util"
 	"github.com/go-openapi/runtime"
-	"github.com/owu-one/gotosocial-sdk/client/admin"
 	"github.com/owu-one/gotosocial-sdk/models"
 )
 
-func Upload(authClient *auth.Client, path, category string, override bool) error {
-	slog.Info("Started uploading emojis", "path", path, "category", category, "override", override)
-	// get emojis data from current instance
-	emojis, err := authClient.Client.Admin.EmojisGet(
-		&admin.EmojisGetParams{
-			Filter: util.Ptr("domain:local"),
-			Limit:  util.Ptr(int64(0)),
-		},
-		// nil,
-		admin.ClientOption(
-			func(op *runtime.ClientOperation) {
-				op.AuthInfo = authClient.Auth
-				// op.PathPattern = "/api/v1/admin/custom_emojis?filter=domain:local&limit=0"
-			},
-		),
-	)
+// Options controls what Upload sends and how.
+type Options struct {
+	// Category the emojis are uploaded to. With Recursive, it's only used for images at the top level.
+	// If it's empty, new emojis go in defaultCategory and replaced ones stay where they are.
+	Category string
+	// Recursive uploads images in subdirectories too, using the name of the directory each is in as its category.
+	Recursive bool
+	// Depth is how many levels of subdirectories Recursive goes into. 0 means no limit.
+	Depth int
+	// CategoryMap renames categories taken from directory names. Unmapped names are used as they are.
+	CategoryMap map[string]string
+	// Index is an index.json, Misskey meta.json or Pleroma pack.json to take each emoji's shortcode and category from.
+	// Images anywhere under the upload directory are matched to it, and ones it doesn't list are skipped.
+	Index string
+	// Filter picks which images to upload, going by the shortcode and category each would get. nil uploads all of them.
+	Filter *filter.Filter
+	// OnConflict is what to do when a shortcode is already taken. Defaults to Skip.
+	OnConflict OnConflict
+	// RenamePrefix and RenameSuffix are added to taken shortcodes when OnConflict is Rename.
+	RenamePrefix string
+	RenameSuffix string
+	// MaxDimension rejects images wider or taller than this many pixels. 0 means no limit.
+	MaxDimension int
+	// ValidateOnly checks the images and prints the report, without uploading anything.
+	ValidateOnly bool
+	// Process, if set, converts and shrinks images before they're checked.
+	Process *ProcessOptions
+}
+
+// Upload uploads the images in path, using their file names as shortcodes.
+// With opts.Recursive, a tree of category directories, such as download saves, is uploaded in one go.
+// Every image is checked against the instance's limits first; ones that fail are reported and left out.
+func Upload(ctx context.Context, authClient *auth.Client, path string, opts Options) error {
+	if opts.OnConflict == "" {
+		opts.OnConflict = Skip
+	}
+	if err := opts.OnConflict.validate(opts.RenamePrefix, opts.RenameSuffix); err != nil {
+		return err
+	}
+
+	slog.Info("Started uploading emojis", "path", path, "category", opts.Category, "recursive", opts.Recursive, "on_conflict", opts.OnConflict)
+
+	c := &collector{opts: opts, seen: map[string]bool{}}
+	if opts.Index != "" {
+		var err error
+		if c.index, err = loadIndex(opts.Index); err != nil {
+			slog.Error("Error reading index", "error", err)
+			return err
+		}
+		c.matched = map[*indexEntry]bool{}
+	}
+	if err := c.collect(path, opts.Category, 0); err != nil {
+		return err
+	}
+	c.reportIndex()
+
+	l, err := instanceLimits(ctx, authClient, opts.MaxDimension)
 	if err != nil {
-		slog.Error("Error getting emojis", "error", err)
+		slog.Error("Error getting instance emoji limits", "error", err)
 		return err
 	}
-	// filter emojis by category
-	currEmojis, err := util.FilterAdminEmojisByCategory(emojis, category)
+
+	collected := c.images
+	unprocessed := 0
+	if opts.Process.enabled() {
+		dir, err := os.MkdirTemp("", "femoji-upload-*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		p := &processor{opts: opts.Process, maxSize: l.maxSize, dir: dir, taken: c.seen}
+		collected = nil
+		for _, image := range c.images {
+			extra, err := p.process(image)
+			if err != nil {
+				slog.Error("Error processing image, leaving it out", "file", image.source, "error", err)
+				unprocessed++
+				continue
+			}
+			collected = append(collected, image)
+			collected = append(collected, extra...)
+		}
+	}
+
+	images := validateAll(collected, l)
+	invalid := len(collected) - len(images) + unprocessed
+	total := len(collected) + unprocessed
+	if opts.ValidateOnly {
+		if invalid > 0 {
+			return fmt.Errorf("%d of %d images failed validation", invalid, total)
+		}
+		return nil
+	}
+
+	// get emojis data from current instance
+	mgr := backend.ForClient(authClient)
+	emojis, err := mgr.ListLocalEmojis(ctx)
 	if err != nil {
-		slog.Error("Error filtering emojis", "error", err)
+		slog.Error("Error getting emojis", "error", err)
 		return err
 	}
+	// Shortcodes are unique across the whole instance, not just within a category.
+	existing := map[string]*models.AdminEmoji{}
+	for _, emoji := range emojis {
+		existing[emoji.Shortcode] = emoji
+	}
+
+	if opts.OnConflict == Fail {
+		taken := 0
+		for _, image := range images {
+			if emoji := existing[image.shortcode]; emoji != nil {
+				slog.Error("Shortcode already exists", "shortcode", image.shortcode, "category", emoji.Category)
+				taken++
+			}
+		}
+		if taken > 0 {
+			return fmt.Errorf("%d of %d shortcodes already exist, so nothing was uploaded", taken, len(images))
+		}
+	}
+
+	u := &uploader{
+		mgr:      mgr,
+		opts:     opts,
+		existing: existing,
+		pending:  c.seen,
+	}
+	reporter := progress.New("uploaded", len(images))
+	for _, image := range images {
+		if ctx.Err() != nil {
+			break
+		}
+		reporter.Record(image.shortcode, u.upload(ctx, image))
+	}
 
-	files, err := os.ReadDir(path)
+	failures := reporter.Finish()
+	u.reportConflicts()
+	if ctx.Err() != nil {
+		slog.Warn("Interrupted! Stopped uploading")
+		return ctx.Err()
+	}
+	if failures == nil && invalid > 0 {
+		return fmt.Errorf("%d of %d images weren't uploaded as they failed validation", invalid, total)
+	}
+	return failures
+}
+
+// defaultCategory is where new emojis go if no category is given for them.
+const defaultCategory = "uncategorized"
+
+// localImage is an image file to upload.
+type localImage struct {
+	// path is the file to upload. It differs from source if the image has been processed.
+	path      string
+	source    string
+	shortcode string
+	// category is the category given for the image, from the command line, its directory or the index. It may be empty.
+	category string
+}
+
+// uploadCategory returns the category to create the emoji in.
+func (i *localImage) uploadCategory() string {
+	return cmp.Or(i.category, defaultCategory)
+}
+
+// collector finds the images to upload.
+type collector struct {
+	opts   Options
+	images []*localImage
+	// seen holds the shortcodes found so far, which have to be unique across every category.
+	seen map[string]bool
+
+	index *index
+	// matched holds the index entries images have been found for.
+	matched map[*indexEntry]bool
+}
+
+// collect adds the images in dir that the filter matches, giving them category.
+// depth is how many levels below the top directory dir is.
+func (c *collector) collect(dir string, category string, depth int) error {
+	f := c.opts.Filter
+	files, err := os.ReadDir(dir)
 	if err != nil {
 		slog.Error("Error reading directory", "error", err)
 		return err
 	}
+
 	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
 		if file.IsDir() {
-			slog.Info("Skipping", file.Name(), "as it is a directory")
+			if !c.descend(file.Name(), depth) {
+				slog.Info("Skipping as it is a directory", "file", path)
+				continue
+			}
+			if err := c.collect(path, c.category(file.Name()), depth+1); err != nil {
+				return err
+			}
 			continue
 		}
 		// check if file is image
-		if !util.IsImage(file.Name()) {
-			slog.Info("Skipping", file.Name(), "as it is not an image")
+		if !util.IsImage(file.Name()) && !c.opts.Process.converts(file.Name()) {
+			if format, ok := unconvertibleFormats[strings.ToLower(filepath.Ext(file.Name()))]; ok && c.opts.Process != nil && c.opts.Process.Convert {
+				slog.Warn("Skipping as it can't be converted, as there's no decoder for its format", "file", path, "format", format)
+			} else {
+				slog.Info("Skipping as it is not an image", "file", path)
+			}
 			continue
 		}
-		// check if filename equals to any emoji shortcode
-		var exist bool
-		var existingEmoji *models.AdminEmoji
-		for _, emoji := range currEmojis {
-			if emoji.Shortcode == strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())) {
-				exist = true
-				existingEmoji = emoji
-				slog.Info("Emoji already exists, will override if --override flag is set", "shortcode", file.Name())
-				break
+		shortcode := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+		category := category
+		if c.index != nil {
+			entry := c.index.lookup(path, shortcode)
+			if entry == nil {
+				slog.Info("Skipping as it isn't in the index", "file", path)
+				continue
 			}
+			c.matched[entry] = true
+			shortcode, category = entry.shortcode, entry.category
 		}
-		if exist && override {
-			slog.Info("Overriding existing emoji", "shortcode", file.Name())
-			// override emoji
-			_, err := authClient.Client.Admin.EmojiUpdate(
-				&admin.EmojiUpdateParams{
-					Type:  "modify",
-					ID:    existingEmoji.ID,
-					Image: runtime.NamedReader(file.Name(), util.OpenFile(path+"/"+file.Name())),
-				},
-				authClient.Auth,
-				func(op *runtime.ClientOperation) {
-					op.ConsumesMediaTypes = []string{"multipart/form-data"}
-				},
-			)
+		if !f.Match(shortcode, cmp.Or(category, defaultCategory)) {
+			slog.Info("Skipping as it doesn't match the filter", "file", path)
+			continue
+		}
+		if f.ChecksAnimation() {
+			animated, err := util.IsAnimated(path)
 			if err != nil {
-				slog.Error("Error overriding", "file", file.Name(), "error", err)
-				// continue to next file
-				continue
-			} else {
-				slog.Info("Skipping", file.Name(), "as it already exists, to override set --override flag")
+				slog.Warn("Couldn't tell whether image is animated, treating it as static", "file", path, "error", err)
+			}
+			if !f.MatchAnimated(animated) {
+				slog.Info("Skipping as it doesn't match the filter", "file", path, "animated", animated)
 				continue
 			}
 		}
-		// upload emoji
-		slog.Info("Uploading emoji", "shortcode", strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())))
-		_, err := authClient.Client.Admin.EmojiCreate(
-			&admin.EmojiCreateParams{
-				Category:  util.Ptr(category),
-				Image:     runtime.NamedReader(file.Name(), util.OpenFile(path+"/"+file.Name())),
-				Shortcode: strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())),
-			},
-			authClient.Auth,
-			func(op *runtime.ClientOperation) {
-				op.ConsumesMediaTypes = []string{"multipart/form-data"}
-			},
-		)
-		if err != nil {
-			slog.Error("Error uploading", "file", file.Name(), "error", err)
-			// continue to next file
+		if c.seen[shortcode] {
+			slog.Warn("Skipping as another image has the same shortcode", "file", path, "shortcode", shortcode)
 			continue
 		}
+		c.seen[shortcode] = true
+		c.images = append(c.images, &localImage{
+			path:      path,
+			source:    path,
+			shortcode: shortcode,
+			category:  category,
+		})
 	}
 	return nil
 }
+
+// descend reports whether to look for images in a subdirectory, depth levels below the top one.
+func (c *collector) descend(name string, depth int) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	// The index says what goes where, wherever the images are.
+	if c.index != nil {
+		return true
+	}
+	return c.opts.Recursive && (c.opts.Depth <= 0 || depth < c.opts.Depth)
+}
+
+// category returns the category for images in a directory.
+func (c *collector) category(dirName string) string {
+	if category, ok := c.opts.CategoryMap[dirName]; ok {
+		return category
+	}
+	return dirName
+}
+
+// uploader uploads images one at a time, keeping track of which shortcodes are taken.
+type uploader struct {
+	mgr      backend.EmojiManager
+	opts     Options
+	existing map[string]*models.AdminEmoji
+	// pending holds the shortcodes of every image in the upload, so renames don't take one that's yet to be uploaded.
+	pending map[string]bool
+	// conflicts records what happened to each image whose shortcode was taken.
+	conflicts []conflict
+}
+
+type conflict struct {
+	shortcode string
+	outcome   string
+}
+
+func (u *uploader) upload(ctx context.Context, image *localImage) progress.Result {
+	emoji := u.existing[image.shortcode]
+	if emoji == nil {
+		return u.create(ctx, image, image.shortcode)
+	}
+
+	switch u.opts.OnConflict {
+	case Skip:
+		slog.Info("Shortcode already exists, skipping", "shortcode", image.shortcode, "category", emoji.Category)
+		u.conflict(image.shortcode, "skipped")
+		return progress.Skipped
+
+	case Replace:
+		// Only move the emoji if we were told where to put it.
+		var category *string
+		if image.category != "" {
+			category = util.Ptr(image.category)
+		}
+		slog.Info("Replacing existing emoji", "shortcode", image.shortcode, "category", emoji.Category)
+		err := u.mgr.UpdateEmoji(
+			ctx,
+			emoji.ID,
+			category,
+			runtime.NamedReader(filepath.Base(image.path), util.OpenFile(image.path)),
+		)
+		if err != nil {
+			if ctx.Err() != nil {
+				return progress.Cancelled
+			}
+			slog.Error("Error replacing", "file", image.source, "error", err)
+			u.conflict(image.shortcode, "failed to replace")
+			return progress.Failed
+		}
+		u.conflict(image.shortcode, "replaced")
+		return progress.Overridden
+
+	case Rename:
+		renamed, err := renamedShortcode(image.shortcode, u.opts.RenamePrefix, u.opts.RenameSuffix, func(shortcode string) bool {
+			return u.existing[shortcode] != nil || u.pending[shortcode]
+		})
+		if err != nil {
+			slog.Error("Error renaming", "shortcode", image.shortcode, "error", err)
+			u.conflict(image.shortcode, "failed to rename")
+			return progress.Failed
+		}
+		slog.Info("Shortcode already exists, uploading under a new one", "shortcode", image.shortcode, "category", emoji.Category, "renamed", renamed)
+		result := u.create(ctx, image, renamed)
+		switch result {
+		case progress.Done:
+			u.conflict(image.shortcode, "renamed to "+renamed)
+			return progress.Renamed
+		case progress.Failed:
+			u.conflict(image.shortcode, "failed to upload as "+renamed)
+		}
+		return result
+	}
+
+	// Fail is handled before anything is uploaded.
+	slog.Error("Shortcode already exists", "shortcode", image.shortcode, "category", emoji.Category)
+	u.conflict(image.shortcode, "failed")
+	return progress.Failed
+}
+
+// create uploads an image as a new emoji.
+func (u *uploader) create(ctx context.Context, image *localImage, shortcode string) progress.Result {
+	slog.Info("Uploading emoji", "shortcode", shortcode)
+	err := u.mgr.CreateEmoji(
+		ctx,
+		shortcode,
+		image.uploadCategory(),
+		runtime.NamedReader(filepath.Base(image.path), util.OpenFile(image.path)),
+	)
+	if err != nil {
+		if ctx.Err() != nil {
+			return progress.Cancelled
+		}
+		slog.Error("Error uploading", "file", image.source, "error", err)
+		return progress.Failed
+	}
+	u.existing[shortcode] = &models.AdminEmoji{Shortcode: shortcode, Category: image.uploadCategory()}
+	return progress.Done
+}
+
+func (u *uploader) conflict(shortcode string, outcome string) {
+	u.conflicts = append(u.conflicts, conflict{shortcode: shortcode, outcome: outcome})
+}
+
+// reportConflicts lists what happened to each shortcode that was already taken.
+func (u *uploader) reportConflicts() {
+	if len(u.conflicts) == 0 {
+		return
+	}
+	sort.Slice(u.conflicts, func(i, j int) bool {
+		return u.conflicts[i].shortcode < u.conflicts[j].shortcode
+	})
+	slog.Info("Some shortcodes were already taken", "count", len(u.conflicts), "on_conflict", u.opts.OnConflict)
+	for _, c := range u.conflicts {
+		slog.Info("Conflict", "shortcode", c.shortcode, "outcome", c.outcome)
+	}
+}
@@ -0,0 +1,130 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/CDN18/femoji-cli/internal/auth"
+	"github.com/CDN18/femoji-cli/internal/own"
+	"github.com/CDN18/femoji-cli/internal/util"
+)
+
+// shortcodePattern is what GoToSocial accepts as a shortcode.
+var shortcodePattern = regexp.MustCompile(fmt.Sprintf(`^\w{1,%d}$`, maxShortcodeLength))
+
+// acceptedFormats are the image formats GoToSocial accepts for emojis, by the extension util.SniffImage gives them.
+// Unlike the size limit, the instance doesn't advertise these.
+var acceptedFormats = map[string]bool{
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// limits are what the instance will accept as an emoji.
+type limits struct {
+	// maxSize is the largest image in bytes, or 0 if the instance doesn't say.
+	maxSize int64
+	// maxDimension is the largest width or height in pixels, or 0 for no limit.
+	maxDimension int
+}
+
+// instanceLimits fetches the instance's emoji limits. maxDimension is ours, as instances don't have one.
+func instanceLimits(ctx context.Context, authClient *auth.Client, maxDimension int) (*limits, error) {
+	l := &limits{maxDimension: maxDimension}
+
+	instance, err := own.Instance(ctx, authClient)
+	if err != nil {
+		return nil, err
+	}
+	if instance.Configuration != nil && instance.Configuration.Emojis != nil {
+		l.maxSize = instance.Configuration.Emojis.EmojiSizeLimit
+	}
+	if l.maxSize <= 0 {
+		slog.Warn("Instance doesn't say how big emojis can be, so sizes won't be checked")
+	}
+	return l, nil
+}
+
+// validate checks an image against the limits, returning what's wrong with it.
+func validate(image *localImage, l *limits) []string {
+	var problems []string
+
+	if !shortcodePattern.MatchString(image.shortcode) {
+		problems = append(problems, fmt.Sprintf("shortcode must be 1 to %d letters, digits or underscores", maxShortcodeLength))
+	}
+
+	info, err := os.Stat(image.path)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+	if l.maxSize > 0 && info.Size() > l.maxSize {
+		problems = append(problems, fmt.Sprintf("%s is over the %s limit", util.FormatSize(info.Size()), util.FormatSize(l.maxSize)))
+	}
+
+	ext, format := util.SniffImageFile(image.path)
+	switch {
+	case ext == "":
+		return append(problems, "not an image format we recognise")
+	case !acceptedFormats[ext]:
+		return append(problems, format+" isn't accepted (use PNG, GIF or WebP)")
+	}
+
+	width, height, err := dimensions(image.path)
+	if err != nil {
+		return append(problems, fmt.Sprintf("couldn't read %s image: %v", format, err))
+	}
+	if l.maxDimension > 0 && (width > l.maxDimension || height > l.maxDimension) {
+		problems = append(problems, fmt.Sprintf("%dx%d is bigger than %dx%d", width, height, l.maxDimension, l.maxDimension))
+	}
+
+	return problems
+}
+
+func dimensions(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+// validateAll checks every image and prints a report of the ones with problems.
+// It returns the images that passed.
+func validateAll(images []*localImage, l *limits) []*localImage {
+	var valid []*localImage
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	invalid := 0
+	for _, image := range images {
+		problems := validate(image, l)
+		if len(problems) == 0 {
+			valid = append(valid, image)
+			continue
+		}
+		if invalid == 0 {
+			_, _ = fmt.Fprintln(w, "FILE\tSHORTCODE\tPROBLEMS")
+		}
+		invalid++
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", image.source, image.shortcode, strings.Join(problems, "; "))
+	}
+	_ = w.Flush()
+
+	slog.Info("Validated images", "valid", len(valid), "invalid", invalid, "max_size", util.FormatSize(l.maxSize))
+	return valid
+}
@@ -1,39 +1,194 @@
 package util
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 )
 
+// nodeinfoSchemas are the NodeInfo schema versions we understand, best first.
+var nodeinfoSchemas = []string{
+	"http://nodeinfo.diaspora.software/ns/schema/2.1",
+	"http://nodeinfo.diaspora.software/ns/schema/2.0",
+	"http://nodeinfo.diaspora.software/ns/schema/1.1",
+	"http://nodeinfo.diaspora.software/ns/schema/1.0",
+}
+
+// NodeInfoDiscovery is the document served at /.well-known/nodeinfo.
+type NodeInfoDiscovery struct {
+	Links []struct {
+		Rel  string `json:"rel"`
+		Href string `json:"href"`
+	} `json:"links"`
+}
+
 type NodeInfo struct {
+	Version  string `json:"version"`
 	Software struct {
-		Name string `json:"name"`
+		Name       string `json:"name"`
+		Version    string `json:"version"`
+		Repository string `json:"repository,omitempty"`
+		Homepage   string `json:"homepage,omitempty"`
 	} `json:"software"`
+	Protocols NodeInfoProtocols `json:"protocols"`
+	Services  struct {
+		Inbound  []string `json:"inbound"`
+		Outbound []string `json:"outbound"`
+	} `json:"services"`
+	OpenRegistrations bool `json:"openRegistrations"`
+	Usage             struct {
+		Users struct {
+			Total          int `json:"total,omitempty"`
+			ActiveHalfyear int `json:"activeHalfyear,omitempty"`
+			ActiveMonth    int `json:"activeMonth,omitempty"`
+		} `json:"users"`
+		LocalPosts int `json:"localPosts,omitempty"`
+	} `json:"usage"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+// NodeInfoProtocols are the federation protocols an instance speaks.
+// NodeInfo 2.x lists them; 1.x splits them into inbound and outbound, which are merged here.
+type NodeInfoProtocols []string
+
+func (p *NodeInfoProtocols) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*p = list
+		return nil
+	}
+
+	var split struct {
+		Inbound  []string `json:"inbound"`
+		Outbound []string `json:"outbound"`
+	}
+	if err := json.Unmarshal(data, &split); err != nil {
+		return err
+	}
+	list = nil
+	for _, protocol := range append(split.Inbound, split.Outbound...) {
+		if !slices.Contains(list, protocol) {
+			list = append(list, protocol)
+		}
+	}
+	*p = list
+	return nil
+}
+
+// GetNodeInfo discovers and fetches the newest NodeInfo document an instance supports.
+// Instances without a discovery document are tried at the conventional 2.0 path.
+func GetNodeInfo(ctx context.Context, instance string) (*NodeInfo, error) {
+	endpoint, err := discoverNodeInfo(ctx, instance)
+	if err != nil {
+		slog.Warn("NodeInfo discovery failed, trying default path", "instance", instance, "error", err)
+		endpoint, err = InstanceURL(instance, "/nodeinfo/2.0")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var nodeinfo NodeInfo
+	if err := GetJSON(ctx, endpoint, &nodeinfo); err != nil {
+		return nil, fmt.Errorf("failed to get nodeinfo: %w", err)
+	}
+	nodeinfo.Software.Name = strings.ToLower(nodeinfo.Software.Name)
+
+	return &nodeinfo, nil
+}
+
+// discoverNodeInfo returns the URL of the newest NodeInfo schema linked from the well-known document.
+func discoverNodeInfo(ctx context.Context, instance string) (string, error) {
+	endpoint, err := InstanceURL(instance, "/.well-known/nodeinfo")
+	if err != nil {
+		return "", err
+	}
+
+	var discovery NodeInfoDiscovery
+	if err := GetJSON(ctx, endpoint, &discovery); err != nil {
+		return "", err
+	}
+
+	for _, schema := range nodeinfoSchemas {
+		for _, link := range discovery.Links {
+			if link.Rel == schema && link.Href != "" {
+				return link.Href, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no supported NodeInfo schema linked from %s", endpoint)
 }
 
-func GetNodeInfo(instance string) (*NodeInfo, error) {
-	endpoint := fmt.Sprintf("https://%s/nodeinfo/2.0", instance)
-	resp, err := http.Get(endpoint)
+// GetJSON fetches a URL and decodes its JSON response.
+func GetJSON(ctx context.Context, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get nodeinfo: %d", resp.StatusCode)
+		return fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var nodeinfo NodeInfo
-	if err := json.Unmarshal(body, &nodeinfo); err != nil {
-		return nil, err
+	return json.Unmarshal(body, v)
+}
+
+// SoftwareVersion returns the leading numeric components of the software version,
+// e.g. [13, 14, 2] for "13.14.2" or [0, 17, 0] for "0.17.0+git-abcdef".
+func (n *NodeInfo) SoftwareVersion() []int {
+	var parts []int
+	for _, field := range strings.Split(n.Software.Version, ".") {
+		end := 0
+		for end < len(field) && field[end] >= '0' && field[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		part, err := strconv.Atoi(field[:end])
+		if err != nil {
+			break
+		}
+		parts = append(parts, part)
+		if end < len(field) {
+			break
+		}
 	}
+	return parts
+}
 
-	return &nodeinfo, nil
+// SoftwareVersionAtLeast returns true if the software version is at least the given one.
+// Unparseable versions are assumed to be recent.
+func (n *NodeInfo) SoftwareVersionAtLeast(want ...int) bool {
+	have := n.SoftwareVersion()
+	if len(have) == 0 {
+		return true
+	}
+	for i, w := range want {
+		h := 0
+		if i < len(have) {
+			h = have[i]
+		}
+		if h != w {
+			return h > w
+		}
+	}
+	return true
 }
@@ -0,0 +1,138 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/gif"
+	"io"
+	"os"
+)
+
+// SniffLength is how much of a file we read to identify it.
+const SniffLength = 4096
+
+// SniffImageFile identifies an image file by its contents. It returns the extension and format name,
+// or empty strings if the format isn't one we recognise.
+func SniffImageFile(path string) (string, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	head := make([]byte, SniffLength)
+	n, _ := io.ReadFull(f, head)
+	return SniffImage(head[:n])
+}
+
+// SniffImage identifies an image by the start of its contents, as SniffImageFile does.
+func SniffImage(head []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		if IsAPNG(head) {
+			return ".png", "APNG"
+		}
+		return ".png", "PNG"
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return ".gif", "GIF"
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return ".webp", "WebP"
+	case IsAVIF(head):
+		return ".avif", "AVIF"
+	case bytes.HasPrefix(head, []byte{0xff, 0xd8, 0xff}):
+		return ".jpg", "JPEG"
+	case IsSVG(head):
+		return ".svg", "SVG"
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return ".tiff", "TIFF"
+	case bytes.HasPrefix(head, []byte("BM")):
+		return ".bmp", "BMP"
+	}
+	return "", ""
+}
+
+// IsAVIF checks the ISO BMFF file type box for an AVIF brand.
+func IsAVIF(head []byte) bool {
+	if len(head) < 16 || string(head[4:8]) != "ftyp" {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(head))
+	if size < 16 || size > len(head) {
+		size = len(head)
+	}
+	// The major brand is at 8; compatible brands start at 16, after the minor version.
+	brands := [][]byte{head[8:12]}
+	for offset := 16; offset+4 <= size; offset += 4 {
+		brands = append(brands, head[offset:offset+4])
+	}
+	for _, brand := range brands {
+		if string(brand) == "avif" || string(brand) == "avis" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSVG reports whether the start of a file looks like an SVG document.
+func IsSVG(head []byte) bool {
+	text := bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	text = bytes.TrimSpace(text)
+	if !bytes.HasPrefix(text, []byte("<")) {
+		return false
+	}
+	return bytes.Contains(text, []byte("<svg"))
+}
+
+// IsAPNG reports whether the start of a PNG file has an animation control chunk,
+// which has to come before the first image data chunk.
+func IsAPNG(head []byte) bool {
+	for offset := 8; offset+8 <= len(head); {
+		length := int(binary.BigEndian.Uint32(head[offset:]))
+		switch string(head[offset+4 : offset+8]) {
+		case "acTL":
+			return true
+		case "IDAT":
+			return false
+		}
+		offset += 12 + length
+	}
+	return false
+}
+
+// IsAnimated reports whether an image file has more than one frame.
+// Formats that can't be animated, or that we don't recognise, count as static.
+func IsAnimated(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, SniffLength)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return IsAPNG(head), nil
+	case bytes.HasPrefix(head, []byte("GIF8")):
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		g, err := gif.DecodeAll(f)
+		if err != nil {
+			return false, err
+		}
+		return len(g.Image) > 1, nil
+	case len(head) >= 21 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		// Extended WebP files have a flags byte, with bit 1 set for animation.
+		return string(head[12:16]) == "VP8X" && head[20]&0x02 != 0, nil
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		// AVIF image sequences use the "avis" brand.
+		return string(head[8:12]) == "avis", nil
+	}
+	return false, nil
+}
@@ -0,0 +1,103 @@
+package util
+
+import (
+	neturl "net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Instances are identified by a string that is either a bare hostname, meaning HTTPS on the default port,
+// or a base URL such as http://localhost:8080 for anything else.
+// NormalizeInstance produces this form, and it's what we store in prefs.
+
+// allowInsecureHTTP permits plain HTTP instances, which are only useful for local development.
+var allowInsecureHTTP bool
+
+// SetAllowInsecureHTTP opts in to talking to instances over plain HTTP.
+func SetAllowInsecureHTTP(allow bool) {
+	allowInsecureHTTP = allow
+}
+
+// ParseInstance parses an instance given as a hostname, host:port, or base URL.
+func ParseInstance(instance string) (*neturl.URL, error) {
+	if instance == "" {
+		return nil, errors.WithStack(errors.New("an instance is required"))
+	}
+
+	raw := instance
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	url, err := neturl.Parse(raw)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if url.Hostname() == "" {
+		return nil, errors.Errorf("instance has no hostname: %s", instance)
+	}
+	if url.User != nil || url.RawQuery != "" || url.Fragment != "" || strings.Trim(url.Path, "/") != "" {
+		return nil, errors.Errorf("instance must be a hostname or base URL without a path: %s", instance)
+	}
+
+	switch url.Scheme {
+	case "https":
+	case "http":
+		if !allowInsecureHTTP {
+			return nil, errors.Errorf("instance uses plain HTTP, pass --insecure-http to allow it: %s", instance)
+		}
+	default:
+		return nil, errors.Errorf("unsupported instance URL scheme: %s", url.Scheme)
+	}
+
+	return &neturl.URL{
+		Scheme: url.Scheme,
+		Host:   strings.ToLower(url.Host),
+	}, nil
+}
+
+// NormalizeInstance returns the canonical form of an instance.
+func NormalizeInstance(instance string) (string, error) {
+	url, err := ParseInstance(instance)
+	if err != nil {
+		return "", err
+	}
+
+	if url.Scheme == "https" && (url.Port() == "" || url.Port() == "443") {
+		return url.Hostname(), nil
+	}
+
+	return url.String(), nil
+}
+
+// InstanceHost returns the host, including any non-default port, of an instance.
+func InstanceHost(instance string) (string, error) {
+	url, err := ParseInstance(instance)
+	if err != nil {
+		return "", err
+	}
+	if url.Scheme == "https" && url.Port() == "443" {
+		return url.Hostname(), nil
+	}
+	return url.Host, nil
+}
+
+// InstanceURL returns the URL of a path on an instance.
+func InstanceURL(instance string, path string) (string, error) {
+	url, err := ParseInstance(instance)
+	if err != nil {
+		return "", err
+	}
+	url.Path = path
+	return url.String(), nil
+}
+
+// InstanceDir returns a directory name for an instance that's safe to use on common filesystems.
+func InstanceDir(instance string) string {
+	host, err := InstanceHost(instance)
+	if err != nil {
+		host = instance
+	}
+	return strings.ReplaceAll(host, ":", "_")
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/adrg/xdg"
 	"github.com/pkg/errors"
@@ -13,10 +14,13 @@ type Prefs struct {
 	Instances   map[string]PrefsInstance `json:"instances,omitempty"`
 	Users       map[string]PrefsUser     `json:"users,omitempty"`
 	DefaultUser string                   `json:"default_user,omitempty"`
+	// CredentialStore selects where secrets are kept: auto, keyring, file, or env.
+	CredentialStore string `json:"credential_store,omitempty"`
 }
 
 type PrefsInstance struct {
-	ClientID string `json:"client_id"`
+	ClientID     string   `json:"client_id"`
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
 }
 
 type PrefsUser struct {
@@ -34,6 +38,11 @@ func init() {
 	prefsPath = filepath.Join(prefsDir, "prefs.json")
 }
 
+// ConfigPath returns the path to a file in the femoji config directory.
+func ConfigPath(name string) string {
+	return filepath.Join(prefsDir, name)
+}
+
 // LoadPrefs returns preferences from disk or an empty prefs object if there are none stored or accessible.
 func LoadPrefs() (*Prefs, error) {
 	f, err := os.Open(prefsPath)
@@ -132,6 +141,21 @@ func SetDefaultUser(user string) error {
 	})
 }
 
+func GetCredentialStore() (string, error) {
+	return getPrefValue(func(prefs *Prefs) (string, bool) {
+		if prefs.CredentialStore == "" {
+			return "", false
+		}
+		return prefs.CredentialStore, true
+	})
+}
+
+func SetCredentialStore(name string) error {
+	return setPrefValue(func(prefs *Prefs) {
+		prefs.CredentialStore = name
+	})
+}
+
 func GetInstanceClientID(instance string) (string, error) {
 	return getPrefValue(func(prefs *Prefs) (string, bool) {
 		prefsInstance, exists := prefs.Instances[instance]
@@ -150,6 +174,29 @@ func SetInstanceClientID(instance string, clientID string) error {
 	})
 }
 
+// GetInstanceRedirectURIs returns the redirect URIs registered with the instance's OAuth2 app.
+func GetInstanceRedirectURIs(instance string) ([]string, error) {
+	prefs, err := LoadPrefs()
+	if err != nil {
+		return nil, err
+	}
+
+	prefsInstance, exists := prefs.Instances[instance]
+	if !exists {
+		return nil, errors.WithStack(PrefNotFound)
+	}
+
+	return prefsInstance.RedirectURIs, nil
+}
+
+func SetInstanceRedirectURIs(instance string, redirectURIs []string) error {
+	return setPrefValue(func(prefs *Prefs) {
+		prefsInstance := prefs.Instances[instance]
+		prefsInstance.RedirectURIs = redirectURIs
+		prefs.Instances[instance] = prefsInstance
+	})
+}
+
 func GetUserInstance(user string) (string, error) {
 	return getPrefValue(func(prefs *Prefs) (string, bool) {
 		prefsUser, exists := prefs.Users[user]
@@ -167,3 +214,37 @@ func SetUserInstance(user string, instance string) error {
 		prefs.Users[user] = prefsUser
 	})
 }
+
+// RemoveUser drops a user from prefs, clearing the default user if it was them.
+func RemoveUser(user string) error {
+	return setPrefValue(func(prefs *Prefs) {
+		delete(prefs.Users, user)
+		if prefs.DefaultUser == user {
+			prefs.DefaultUser = ""
+		}
+	})
+}
+
+// RemoveInstance drops an instance's stored app registration from prefs.
+func RemoveInstance(instance string) error {
+	return setPrefValue(func(prefs *Prefs) {
+		delete(prefs.Instances, instance)
+	})
+}
+
+// InstanceUsers returns the users in prefs who are logged in to an instance.
+func InstanceUsers(instance string) ([]string, error) {
+	prefs, err := LoadPrefs()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []string
+	for user, prefsUser := range prefs.Users {
+		if prefsUser.Instance == instance {
+			users = append(users, user)
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
@@ -0,0 +1,258 @@
+package util
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+// HTTPClient is shared by everything that talks to an instance, so rate limits are enforced across all of it.
+var HTTPClient = &http.Client{
+	Transport: NewTransport(http.DefaultTransport),
+}
+
+const (
+	// hostRequestRate and hostRequestBurst match Mastodon's default limit of 300 requests per 5 minutes.
+	hostRequestRate  = rate.Limit(1.0)
+	hostRequestBurst = 300
+
+	maxRetries     = 5
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+	// maxRateLimitWait caps how long we trust a server's reset time, in case it's nonsense.
+	maxRateLimitWait = 15 * time.Minute
+)
+
+// Transport is an http.RoundTripper that rate limits requests per host,
+// waits for a host's rate limit to reset once it's been exhausted,
+// and retries transient failures with jittered exponential backoff.
+type Transport struct {
+	base http.RoundTripper
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	limiter *rate.Limiter
+	// blockedUntil is when the host's rate limit resets after it told us we've run out.
+	blockedUntil time.Time
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{
+		base:  base,
+		hosts: map[string]*hostState{},
+	}
+}
+
+func (t *Transport) host(host string) *hostState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.hosts[host]
+	if !ok {
+		state = &hostState{limiter: rate.NewLimiter(hostRequestRate, hostRequestBurst)}
+		t.hosts[host] = state
+	}
+	return state
+}
+
+func (t *Transport) blockHost(host string, until time.Time) {
+	if limit := time.Now().Add(maxRateLimitWait); until.After(limit) {
+		until = limit
+	}
+
+	state := t.host(host)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until.After(state.blockedUntil) {
+		state.blockedUntil = until
+	}
+}
+
+// wait blocks until a request to host is allowed.
+func (t *Transport) wait(ctx context.Context, host string) error {
+	state := t.host(host)
+
+	t.mu.Lock()
+	blockedUntil := state.blockedUntil
+	t.mu.Unlock()
+
+	if delay := time.Until(blockedUntil); delay > 0 {
+		slog.Info("waiting for rate limit to reset", "host", host, "until", blockedUntil.Format(time.RFC3339))
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+
+	return state.limiter.Wait(ctx)
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	retryable := canRetry(req)
+
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req.Context(), host); err != nil {
+			return nil, err
+		}
+
+		attemptReq := req
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil {
+			if attempt >= maxRetries || !retryable || !isTransient(err) || req.Context().Err() != nil {
+				return nil, err
+			}
+			delay := backoff(attempt)
+			slog.Warn("request failed, retrying", "host", host, "error", err, "attempt", attempt+1, "delay", delay)
+			if err := sleep(req.Context(), delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if reset, ok := rateLimitReset(resp.Header); ok {
+			t.blockHost(host, reset)
+		}
+
+		// A 429 means the server didn't act on the request, so it's always safe to repeat it.
+		shouldRetry := resp.StatusCode == http.StatusTooManyRequests ||
+			(retryable && isTransientStatus(resp.StatusCode))
+		if !shouldRetry || attempt >= maxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		delay := backoff(attempt)
+		if until, ok := retryAfter(resp.Header); ok {
+			t.blockHost(host, until)
+			delay = 0
+		}
+		slog.Warn("request failed, retrying", "host", host, "status", resp.StatusCode, "attempt", attempt+1, "delay", delay)
+		resp.Body.Close()
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// canRetry reports whether a request can be repeated after an error we can't be sure the server didn't act on.
+func canRetry(req *http.Request) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func isTransient(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Timeouts, connection resets, refused connections, DNS hiccups and the like are all net.Errors.
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func isTransientStatus(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before retry number attempt+1, with full jitter.
+func backoff(attempt int) time.Duration {
+	limit := retryBaseDelay << attempt
+	if limit > retryMaxDelay || limit <= 0 {
+		limit = retryMaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(limit)))
+}
+
+// rateLimitReset returns when the host's rate limit resets, if the response says we've used it up.
+func rateLimitReset(header http.Header) (time.Time, bool) {
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}, false
+	}
+
+	reset, ok := parseResetTime(header.Get("X-RateLimit-Reset"))
+	if !ok {
+		slog.Warn("couldn't parse X-RateLimit-Reset header, waiting 5 minutes", "value", header.Get("X-RateLimit-Reset"))
+		reset = time.Now().Add(5 * time.Minute)
+	}
+	return reset, true
+}
+
+// retryAfter returns when the Retry-After header says to try again.
+func retryAfter(header http.Header) (time.Time, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Now().Add(time.Duration(seconds) * time.Second), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// parseResetTime parses an X-RateLimit-Reset value, which Mastodon and GtS send as ISO 8601
+// and other servers as an HTTP date or a number of seconds.
+func parseResetTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, time.RFC1123, time.RFC1123Z, time.RFC850, time.ANSIC} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Large values are Unix timestamps; small ones are seconds from now.
+		if seconds > 1_000_000_000 {
+			return time.Unix(seconds, 0), true
+		}
+		return time.Now().Add(time.Duration(seconds) * time.Second), true
+	}
+	return time.Time{}, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -1,16 +1,14 @@
 package util
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"strings"
-
-	"github.com/owu-one/gotosocial-sdk/client/admin"
-	"github.com/owu-one/gotosocial-sdk/models"
 )
 
 func IsImage(name string) bool {
-	suffixes := []string{".png", ".gif"}
+	suffixes := []string{".png", ".gif", ".webp"}
 	for _, suffix := range suffixes {
 		if strings.HasSuffix(name, suffix) {
 			return true
@@ -19,26 +17,6 @@ func IsImage(name string) bool {
 	return false
 }
 
-func FilterAdminEmojisByCategory(emojis *admin.EmojisGetOK, category string) ([]*models.AdminEmoji, error) {
-	var filtered []*models.AdminEmoji
-	for _, emoji := range emojis.Payload {
-		if emoji.Category == category {
-			filtered = append(filtered, emoji)
-		}
-	}
-	return filtered, nil
-}
-
-func FilterEmojisByCategory(emojis []*models.Emoji, category string) ([]*models.Emoji, error) {
-	var filtered []*models.Emoji
-	for _, emoji := range emojis {
-		if emoji.Category == category {
-			filtered = append(filtered, emoji)
-		}
-	}
-	return filtered, nil
-}
-
 func OpenFile(path string) io.Reader {
 	file, err := os.Open(path)
 	if err != nil {
@@ -46,3 +24,16 @@ func OpenFile(path string) io.Reader {
 	}
 	return file
 }
+
+// FormatSize renders a byte count for people, e.g. "12.3 KiB", or "-" if it's unknown.
+func FormatSize(size int64) string {
+	switch {
+	case size <= 0:
+		return "-"
+	case size < 1<<10:
+		return fmt.Sprintf("%d B", size)
+	case size < 1<<20:
+		return fmt.Sprintf("%.1f KiB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+}